@@ -0,0 +1,234 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+// Conditions and condition Reasons for the KindCluster object.
+// The summary of all of them is reported in the Ready condition (clusterv1.ReadyCondition).
+
+const (
+	// KindClusterCreatedCondition documents the creation of the kind cluster on the container runtime.
+	KindClusterCreatedCondition clusterv1.ConditionType = "KindClusterCreated"
+
+	// KindClusterProvisioningReason (Severity=Info) documents a kind cluster being created in background.
+	KindClusterProvisioningReason = "Provisioning"
+
+	// KindClusterPartiallyCreatedReason (Severity=Warning) documents a kind cluster left half-made by an
+	// interrupted creation, which is deleted before being created again.
+	KindClusterPartiallyCreatedReason = "KindClusterPartiallyCreated"
+
+	// KindClusterCreateFailedReason (Severity=Error) documents a kind cluster that failed to be created.
+	KindClusterCreateFailedReason = "KindClusterCreateFailed"
+
+	// KindClusterNotFoundReason (Severity=Warning) documents a kind cluster whose node containers have disappeared,
+	// which is created again.
+	KindClusterNotFoundReason = "KindClusterNotFound"
+
+	// KindClusterImportPendingReason (Severity=Warning) documents a kind cluster to import that does not exist yet.
+	KindClusterImportPendingReason = "KindClusterImportPending"
+
+	// KindClusterNameConflictReason (Severity=Error) documents a kind cluster with the same name already existing
+	// and not owned by the KindCluster.
+	KindClusterNameConflictReason = "KindClusterNameConflict"
+
+	// KindClusterDeletingReason (Severity=Info) documents a kind cluster being deleted.
+	KindClusterDeletingReason = "Deleting"
+
+	// KindClusterDeleteFailedReason (Severity=Warning) documents a kind cluster that failed to be deleted.
+	KindClusterDeleteFailedReason = "KindClusterDeleteFailed"
+)
+
+const (
+	// RuntimeAvailableCondition documents the availability of the container runtime of the kind cluster,
+	// see Spec.Runtime.
+	RuntimeAvailableCondition clusterv1.ConditionType = "RuntimeAvailable"
+
+	// RuntimeUnavailableReason (Severity=Error) documents a container runtime that is not installed or not reachable
+	// by the controller.
+	RuntimeUnavailableReason = "RuntimeUnavailable"
+
+	// HostUnavailableReason (Severity=Error) documents a KindHost of Spec.HostRef that does not exist or whose
+	// credentials are invalid.
+	HostUnavailableReason = "HostUnavailable"
+)
+
+const (
+	// HostScheduledCondition documents the choice of a KindHost for a KindCluster with Spec.HostSelector.
+	HostScheduledCondition clusterv1.ConditionType = "HostScheduled"
+
+	// NoHostAvailableReason (Severity=Warning) documents a KindCluster no KindHost matching Spec.HostSelector
+	// has room for.
+	NoHostAvailableReason = "NoHostAvailable"
+)
+
+const (
+	// ImageResolvedCondition documents the resolution of the node image from Spec.Image and Spec.K8sVersion.
+	ImageResolvedCondition clusterv1.ConditionType = "ImageResolved"
+
+	// ImageResolutionFailedReason (Severity=Error) documents a node image that cannot be resolved,
+	// e.g. a Kubernetes version kind does not publish a node image for.
+	ImageResolutionFailedReason = "ImageResolutionFailed"
+)
+
+const (
+	// NodesHealthyCondition documents the node containers of the kind cluster existing and running, as its spec expects.
+	NodesHealthyCondition clusterv1.ConditionType = "NodesHealthy"
+
+	// NodesStoppedReason (Severity=Warning) documents node containers of the kind cluster that are not running.
+	NodesStoppedReason = "NodesStopped"
+
+	// NodesMissingReason (Severity=Error) documents node containers of the kind cluster that have been removed.
+	NodesMissingReason = "NodesMissing"
+
+	// RemediatingReason (Severity=Info) documents stopped or missing node containers being restarted or recreated
+	// according to Spec.Remediation.
+	RemediatingReason = "Remediating"
+
+	// RemediationFailedReason (Severity=Warning) documents stopped or missing node containers that failed to be
+	// restarted or recreated.
+	RemediationFailedReason = "RemediationFailed"
+)
+
+const (
+	// ControlPlaneReadyCondition documents the readiness of the kind cluster control plane nodes.
+	ControlPlaneReadyCondition clusterv1.ConditionType = "ControlPlaneReady"
+
+	// WaitingForControlPlaneReason (Severity=Info) documents a kind cluster whose control plane nodes are not ready yet.
+	WaitingForControlPlaneReason = "WaitingForControlPlane"
+)
+
+const (
+	// APIServerHealthyCondition documents the API server of the kind cluster answering its /readyz endpoint,
+	// reached with the kubeconfig of the cluster.
+	APIServerHealthyCondition clusterv1.ConditionType = "APIServerHealthy"
+
+	// APIServerUnhealthyReason (Severity=Warning) documents an API server that cannot be reached or is not ready.
+	APIServerUnhealthyReason = "APIServerUnhealthy"
+
+	// NodesReadyCondition documents all the nodes of the kind cluster registered and Ready.
+	NodesReadyCondition clusterv1.ConditionType = "NodesReady"
+
+	// NodesNotReadyReason (Severity=Warning) documents nodes of the kind cluster not registered or not Ready yet.
+	NodesNotReadyReason = "NodesNotReady"
+
+	// SystemPodsReadyCondition documents the control plane, DNS and kube-proxy pods of the kind cluster being Ready.
+	SystemPodsReadyCondition clusterv1.ConditionType = "SystemPodsReady"
+
+	// SystemPodsNotReadyReason (Severity=Warning) documents system pods of the kind cluster missing or not Ready yet.
+	SystemPodsNotReadyReason = "SystemPodsNotReady"
+)
+
+const (
+	// EndpointResolvedCondition documents the resolution of the kind cluster API server endpoint
+	// into Spec.ControlPlaneEndpoint.
+	EndpointResolvedCondition clusterv1.ConditionType = "EndpointResolved"
+
+	// EndpointUnavailableReason (Severity=Warning) documents an API server endpoint that cannot be resolved.
+	EndpointUnavailableReason = "EndpointUnavailable"
+)
+
+const (
+	// KubeconfigPublishedCondition documents the publication of the kind cluster kubeconfig
+	// in the <cluster>-kubeconfig Secret.
+	KubeconfigPublishedCondition clusterv1.ConditionType = "KubeconfigPublished"
+
+	// KubeconfigPublishFailedReason (Severity=Warning) documents a kubeconfig that cannot be published.
+	KubeconfigPublishFailedReason = "KubeconfigPublishFailed"
+)
+
+const (
+	// WorkersReadyCondition documents the worker nodes of the kind cluster matching Spec.WorkerCount.
+	WorkersReadyCondition clusterv1.ConditionType = "WorkersReady"
+
+	// ScalingUpReason (Severity=Info) documents worker nodes being added to the kind cluster.
+	ScalingUpReason = "ScalingUp"
+
+	// ScalingDownReason (Severity=Info) documents worker nodes being removed from the kind cluster.
+	ScalingDownReason = "ScalingDown"
+
+	// ScalingFailedReason (Severity=Warning) documents a worker node that failed to be added or removed.
+	ScalingFailedReason = "ScalingFailed"
+)
+
+const (
+	// UpToDateCondition documents the kind cluster running the node image, and the configuration, of its spec.
+	UpToDateCondition clusterv1.ConditionType = "UpToDate"
+
+	// UpgradingReason (Severity=Info) documents a kind cluster being moved to a new node image.
+	UpgradingReason = "Upgrading"
+
+	// UpgradeFailedReason (Severity=Warning) documents a kind cluster that failed to be moved to a new node image.
+	UpgradeFailedReason = "UpgradeFailed"
+
+	// DowngradeNotAllowedReason (Severity=Error) documents a new node image with an older Kubernetes version,
+	// refused unless Spec.AllowDowngrade is set.
+	DowngradeNotAllowedReason = "DowngradeNotAllowed"
+
+	// InvalidConfigurationReason (Severity=Error) documents a spec that cannot be applied to the created kind cluster,
+	// e.g. a kubeadm config patch of the new nodes, until it changes.
+	InvalidConfigurationReason = "InvalidConfiguration"
+)
+
+const (
+	// RegistryReadyCondition documents the local image registry of Spec.Registry running and published
+	// in the local-registry-hosting ConfigMap of the kind cluster.
+	RegistryReadyCondition clusterv1.ConditionType = "RegistryReady"
+
+	// RegistryFailedReason (Severity=Warning) documents a local image registry that cannot be started or published.
+	RegistryFailedReason = "RegistryFailed"
+)
+
+// Conditions and condition Reasons for the KindMachine object.
+
+const (
+	// NodeProvisionedCondition documents the creation of the kind node backing the KindMachine
+	// and its join to the kind cluster.
+	NodeProvisionedCondition clusterv1.ConditionType = "NodeProvisioned"
+
+	// WaitingForClusterInfrastructureReason (Severity=Info) documents a KindMachine waiting for the KindCluster
+	// to be ready before creating its node.
+	WaitingForClusterInfrastructureReason = "WaitingForClusterInfrastructure"
+
+	// WaitingForBootstrapDataReason (Severity=Info) documents a KindMachine waiting for the bootstrap data of its Machine
+	// to be set before creating its node.
+	WaitingForBootstrapDataReason = "WaitingForBootstrapData"
+
+	// NodeProvisioningReason (Severity=Info) documents a kind node being created in background.
+	NodeProvisioningReason = "NodeProvisioning"
+
+	// NodeProvisionFailedReason (Severity=Warning) documents a kind node that failed to be created.
+	NodeProvisionFailedReason = "NodeProvisionFailed"
+
+	// NodeUnsupportedReason (Severity=Error) documents a Machine that cannot be backed by a kind node,
+	// e.g. a control plane Machine.
+	NodeUnsupportedReason = "NodeUnsupported"
+
+	// NodeDeletingReason (Severity=Info) documents a kind node being deleted.
+	NodeDeletingReason = "Deleting"
+)
+
+// Conditions and condition Reasons for the KindHost object.
+
+const (
+	// HostReachableCondition documents the docker daemon of the KindHost answering with its credentials.
+	HostReachableCondition clusterv1.ConditionType = "HostReachable"
+
+	// HostUnreachableReason (Severity=Error) documents a docker daemon that cannot be reached, or a KindHost Secret
+	// that is missing or invalid.
+	HostUnreachableReason = "HostUnreachable"
+)
@@ -17,13 +17,26 @@ limitations under the License.
 package v1beta1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 )
 
 const (
 	// Be sure to be called before object removal from the apiserver.
 	KindClusterFinalizer = "kindcluster.infrastructure.cluster.x-k8s.io"
+
+	// Label set on every node of a kind cluster, holding the UID of the KindCluster owning it.
+	KindClusterUIDLabel = "infrastructure.cluster.x-k8s.io/kindcluster-uid"
+
+	// Annotation disabling the validation of the feature gates names against the Kubernetes version of the KindCluster,
+	// for gates the provider does not know yet.
+	SkipFeatureGatesValidationAnnotation = "infrastructure.cluster.x-k8s.io/skip-feature-gates-validation"
+
+	// Annotation asking for the creation of the kind cluster to be attempted again once it gave up, without changing
+	// the spec, removed by the controller.
+	RetryCreateAnnotation = "infrastructure.cluster.x-k8s.io/retry-create"
 )
 
 // KindClusterSpec defines the desired state of KindCluster
@@ -44,12 +57,417 @@ type KindClusterSpec struct {
 
 	K8sVersion string `json:"k8sVersion,omitempty"`
 
+	// Name of the kind cluster to create, defaults to a name derived from namespace and name of the KindCluster
+	//+optional
+	//+kubebuilder:validation:MaxLength=40
+	//+kubebuilder:validation:Pattern=`^[a-z0-9.-]+$`
+	KindClusterName string `json:"kindClusterName,omitempty"`
+
+	// Import the existing kind cluster named spec.kindClusterName, e.g. created with the kind CLI, instead of creating
+	// one. Its nodes and image are read back into the status, the topology and the image of the spec are ignored:
+	// it is neither scaled, upgraded nor remediated. Cannot be changed once set.
+	//+optional
+	Import bool `json:"import,omitempty"`
+
+	// Container runtime running the kind nodes, defaults to the runtime of the controller (its --container-runtime flag,
+	// detected otherwise). Cannot be changed once the cluster is created.
+	//+optional
+	Runtime ContainerRuntime `json:"runtime,omitempty"`
+
+	// Remote container host running the kind nodes, a KindHost in the namespace of the KindCluster, defaults to the
+	// container host of the controller. Remote hosts run docker. Cannot be changed once the cluster is created.
+	//+optional
+	HostRef *corev1.LocalObjectReference `json:"hostRef,omitempty"`
+
+	// Schedules the kind cluster on a KindHost of its namespace matching the selector, with room for its nodes,
+	// when spec.hostRef is not set. An empty selector matches all the KindHosts. The chosen host is recorded
+	// in status.host.
+	//+optional
+	HostSelector *metav1.LabelSelector `json:"hostSelector,omitempty"`
+
 	// KIND image to use, see https://github.com/kubernetes-sigs/kind/releases for a list
 
-	//+kubebuilder:default="kindest/node:v1.25.2@sha256:9be91e9e9cdf116809841fc77ebdb8845443c4c72fe5218f3ae9eb57fdb4bace"
+	//+kubebuilder:default="kindest/node:v1.30.0@sha256:047357ac0cfea04663786a612ba1eaba9702bef25227a794b52890dd8bcd692e"
 	Image string `json:"image,omitempty"`
+
+	// Strategy used to move an existing kind cluster to a new image or Kubernetes version
+	//+optional
+	//+kubebuilder:default=RollingUpdate
+	UpgradeStrategy UpgradeStrategyType `json:"upgradeStrategy,omitempty"`
+
+	// Allow moving an existing kind cluster to an older Kubernetes version
+	//+optional
+	AllowDowngrade bool `json:"allowDowngrade,omitempty"`
+
+	// Number of attempts to create the kind cluster before giving up, with an exponential backoff between them.
+	// Once given up, the creation is attempted again when the spec changes or the
+	// infrastructure.cluster.x-k8s.io/retry-create annotation is set.
+	//+optional
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:default=5
+	MaxCreateAttempts int32 `json:"maxCreateAttempts,omitempty"`
+
+	// Action taken when node containers of the kind cluster are found stopped or missing, the drift is only
+	// reported by default
+	//+optional
+	//+kubebuilder:default=None
+	Remediation RemediationPolicy `json:"remediation,omitempty"`
+
+	// What happens to the kind cluster when the KindCluster is deleted, its containers are removed by default
+	//+optional
+	//+kubebuilder:default=Delete
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// Networking of the kind cluster, cannot be changed once the cluster is created
+	//+optional
+	Networking KindNetworking `json:"networking,omitempty"`
+
+	// Pools of nodes added to the controlPlaneCount control plane nodes and workerCount worker nodes,
+	// each with its own configuration. Cannot be changed once the cluster is created.
+	//+optional
+	//+listType=map
+	//+listMapKey=name
+	NodePools []NodePool `json:"nodePools,omitempty"`
+
+	// Merge patches applied to the kubeadm configuration of all the nodes, before the patches of the node pools.
+	// Matched on the kind (and apiVersion if set) of the kubeadm configuration objects, see
+	// https://kind.sigs.k8s.io/docs/user/configuration/#kubeadm-config-patches. Cannot be changed once the cluster is created.
+	//+optional
+	KubeadmConfigPatches []string `json:"kubeadmConfigPatches,omitempty"`
+
+	// JSON 6902 patches applied to the kubeadm configuration of all the nodes. Cannot be changed once the cluster is created.
+	//+optional
+	KubeadmConfigPatchesJSON6902 []PatchJSON6902 `json:"kubeadmConfigPatchesJSON6902,omitempty"`
+
+	// TOML merge patches applied to the containerd configuration of all the nodes, e.g. to set registry mirrors.
+	// Cannot be changed once the cluster is created.
+	//+optional
+	ContainerdConfigPatches []string `json:"containerdConfigPatches,omitempty"`
+
+	// JSON 6902 patches applied to the containerd configuration of all the nodes. Cannot be changed once the cluster is created.
+	//+optional
+	ContainerdConfigPatchesJSON6902 []string `json:"containerdConfigPatchesJSON6902,omitempty"`
+
+	// Feature gates enabled or disabled on all the Kubernetes components, see
+	// https://kubernetes.io/docs/reference/command-line-tools-reference/feature-gates/. Cannot be changed once the cluster is created.
+	//+optional
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+
+	// API groups and versions enabled or disabled on the API server (--runtime-config), e.g. "api/alpha": "true".
+	// Cannot be changed once the cluster is created.
+	//+optional
+	RuntimeConfig map[string]string `json:"runtimeConfig,omitempty"`
+
+	// Local image registry run by the provider next to the kind cluster, used as a mirror by all the nodes.
+	// Cannot be changed once the cluster is created.
+	//+optional
+	Registry *KindRegistry `json:"registry,omitempty"`
+
+	// Images loaded into all the nodes of the kind cluster, the nodes added later included, so that they are not pulled:
+	// references of images of the docker host, or absolute paths of image archives (docker save) readable by the controller
+	//+optional
+	//+listType=set
+	PreloadImages []string `json:"preloadImages,omitempty"`
 }
 
+// KindNetworking defines the networking of the kind cluster, see https://kind.sigs.k8s.io/docs/user/configuration/#networking
+type KindNetworking struct {
+
+	// IP family of the cluster, defaults to ipv4
+	//+optional
+	IPFamily IPFamily `json:"ipFamily,omitempty"`
+
+	// CIDR used for pod IPs, a comma separated IPv4 and IPv6 pair for dual-stack clusters.
+	// Defaults to the pods CIDR blocks of the Cluster clusterNetwork, to the kind default otherwise.
+	//+optional
+	PodSubnet string `json:"podSubnet,omitempty"`
+
+	// CIDR used for service VIPs, a comma separated IPv4 and IPv6 pair for dual-stack clusters.
+	// Defaults to the services CIDR blocks of the Cluster clusterNetwork, to the kind default otherwise.
+	//+optional
+	ServiceSubnet string `json:"serviceSubnet,omitempty"`
+
+	// Do not install the default CNI (kindnet), a CNI must be installed in the cluster for the nodes to be ready
+	//+optional
+	DisableDefaultCNI bool `json:"disableDefaultCNI,omitempty"`
+
+	// Mode of kube-proxy, defaults to iptables
+	//+optional
+	KubeProxyMode KubeProxyMode `json:"kubeProxyMode,omitempty"`
+
+	// Address on the host the API server listens on, defaults to 127.0.0.1
+	//+optional
+	APIServerAddress string `json:"apiServerAddress,omitempty"`
+
+	// Port on the host the API server listens on, defaults to a random port
+	//+optional
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:Maximum=65535
+	APIServerPort int32 `json:"apiServerPort,omitempty"`
+}
+
+// KindRegistry defines the local image registry of a kind cluster, see https://kind.sigs.k8s.io/docs/user/local-registry/
+type KindRegistry struct {
+
+	// Image of the registry container
+	//+kubebuilder:default="registry:2"
+	//+optional
+	Image string `json:"image,omitempty"`
+
+	// Port the registry is published on at localhost on the docker host, images pushed to localhost:<hostPort>/<image>
+	// are pulled by the nodes under the same name. Must be unique among the KindClusters with a registry.
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:validation:Maximum=65535
+	//+kubebuilder:default=5001
+	//+optional
+	HostPort int32 `json:"hostPort,omitempty"`
+}
+
+// NodePool is a group of kind nodes sharing the same configuration
+type NodePool struct {
+
+	// Name of the pool, unique in the KindCluster
+	//+kubebuilder:validation:MinLength=1
+	//+kubebuilder:validation:MaxLength=63
+	//+kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// Role of the nodes of the pool
+	//+kubebuilder:default=worker
+	Role NodeRole `json:"role,omitempty"`
+
+	// Number of nodes in the pool
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:default=1
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// KIND image of the nodes of the pool, defaults to the image of the cluster
+	//+optional
+	Image string `json:"image,omitempty"`
+
+	// Kubernetes labels of the nodes of the pool
+	//+optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Kubernetes taints of the nodes of the pool
+	//+optional
+	Taints []corev1.Taint `json:"taints,omitempty"`
+
+	// Host paths mounted in the node containers of the pool
+	//+optional
+	ExtraMounts []Mount `json:"extraMounts,omitempty"`
+
+	// Host ports forwarded to the node containers of the pool, use a single replica pool when a host port is set
+	//+optional
+	ExtraPortMappings []PortMapping `json:"extraPortMappings,omitempty"`
+
+	// Merge patches applied to the kubeadm configuration of the nodes of the pool,
+	// matched on the kind (and apiVersion if set) of the kubeadm configuration objects
+	//+optional
+	KubeadmConfigPatches []string `json:"kubeadmConfigPatches,omitempty"`
+
+	// JSON 6902 patches applied to the kubeadm configuration of the nodes of the pool
+	//+optional
+	KubeadmConfigPatchesJSON6902 []PatchJSON6902 `json:"kubeadmConfigPatchesJSON6902,omitempty"`
+}
+
+// NodeRole is the role of a kind node
+// +kubebuilder:validation:Enum=control-plane;worker
+type NodeRole string
+
+const (
+	ControlPlaneRole NodeRole = "control-plane"
+	WorkerRole       NodeRole = "worker"
+)
+
+// Mount is a host path mounted in a node container
+type Mount struct {
+
+	// Path of the mount in the node container
+	ContainerPath string `json:"containerPath"`
+
+	// Path of the mounted directory or file on the host, must be absolute
+	HostPath string `json:"hostPath"`
+
+	// Mount read-only
+	//+optional
+	ReadOnly bool `json:"readOnly,omitempty"`
+
+	// Relabel the host path for SELinux
+	//+optional
+	SelinuxRelabel bool `json:"selinuxRelabel,omitempty"`
+
+	// Propagation of the mount, defaults to None
+	//+optional
+	Propagation MountPropagation `json:"propagation,omitempty"`
+}
+
+// MountPropagation is the propagation of a mount
+// +kubebuilder:validation:Enum=None;HostToContainer;Bidirectional
+type MountPropagation string
+
+const (
+	MountPropagationNone            MountPropagation = "None"
+	MountPropagationHostToContainer MountPropagation = "HostToContainer"
+	MountPropagationBidirectional   MountPropagation = "Bidirectional"
+)
+
+// PortMapping is a host port forwarded to a node container
+type PortMapping struct {
+
+	// Port in the node container
+	//+kubebuilder:validation:Minimum=1
+	//+kubebuilder:validation:Maximum=65535
+	ContainerPort int32 `json:"containerPort"`
+
+	// Port on the host, defaults to a random port
+	//+optional
+	//+kubebuilder:validation:Minimum=0
+	//+kubebuilder:validation:Maximum=65535
+	HostPort int32 `json:"hostPort,omitempty"`
+
+	// Address on the host the port is bound to, defaults to all the addresses of the IP family of the cluster
+	//+optional
+	ListenAddress string `json:"listenAddress,omitempty"`
+
+	// Protocol of the port, defaults to TCP
+	//+optional
+	Protocol PortMappingProtocol `json:"protocol,omitempty"`
+}
+
+// PortMappingProtocol is the protocol of a port mapping
+// +kubebuilder:validation:Enum=TCP;UDP;SCTP
+type PortMappingProtocol string
+
+const (
+	PortMappingProtocolTCP  PortMappingProtocol = "TCP"
+	PortMappingProtocolUDP  PortMappingProtocol = "UDP"
+	PortMappingProtocolSCTP PortMappingProtocol = "SCTP"
+)
+
+// PatchJSON6902 is a JSON 6902 patch applied to the kubeadm configuration objects of the given group, version and kind
+type PatchJSON6902 struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+
+	// JSON 6902 patch, in JSON or YAML
+	Patch string `json:"patch"`
+}
+
+// IPFamily is the IP family of a kind cluster
+// +kubebuilder:validation:Enum=ipv4;ipv6;dual
+type IPFamily string
+
+const (
+	IPv4Family      IPFamily = "ipv4"
+	IPv6Family      IPFamily = "ipv6"
+	DualStackFamily IPFamily = "dual"
+)
+
+// KubeProxyMode is the mode kube-proxy runs in
+// +kubebuilder:validation:Enum=iptables;ipvs
+type KubeProxyMode string
+
+const (
+	IPTablesProxyMode KubeProxyMode = "iptables"
+	IPVSProxyMode     KubeProxyMode = "ipvs"
+)
+
+// UpgradeStrategyType is the strategy used to upgrade a kind cluster
+// +kubebuilder:validation:Enum=Recreate;RollingUpdate
+type UpgradeStrategyType string
+
+const (
+	// Delete the kind cluster and create it again with the new image
+	RecreateUpgradeStrategyType UpgradeStrategyType = "Recreate"
+	// Upgrade the control plane nodes with kubeadm, then replace the worker nodes one by one
+	RollingUpdateUpgradeStrategyType UpgradeStrategyType = "RollingUpdate"
+)
+
+// RemediationPolicy is the action taken on the stopped or missing node containers of a kind cluster
+// +kubebuilder:validation:Enum=None;Restart;Recreate
+type RemediationPolicy string
+
+const (
+	// Report the stopped and missing nodes only
+	NoneRemediationPolicy RemediationPolicy = "None"
+	// Start the stopped node containers
+	RestartRemediationPolicy RemediationPolicy = "Restart"
+	// Start the stopped node containers and create the missing worker nodes again. The whole kind cluster is
+	// deleted and created again when a control plane node or the load balancer is missing.
+	RecreateRemediationPolicy RemediationPolicy = "Recreate"
+)
+
+// DeletionPolicy is what happens to the kind cluster of a deleted KindCluster
+// +kubebuilder:validation:Enum=Delete;Orphan
+type DeletionPolicy string
+
+const (
+	// Remove the node containers of the kind cluster
+	DeleteDeletionPolicy DeletionPolicy = "Delete"
+	// Leave the kind cluster running, e.g. to give an imported cluster back to the kind CLI
+	OrphanDeletionPolicy DeletionPolicy = "Orphan"
+)
+
+// ContainerRuntime is a container runtime kind can run nodes with
+// +kubebuilder:validation:Enum=docker;podman;nerdctl
+type ContainerRuntime string
+
+const (
+	DockerRuntime  ContainerRuntime = "docker"
+	PodmanRuntime  ContainerRuntime = "podman"
+	NerdctlRuntime ContainerRuntime = "nerdctl"
+)
+
+// PreloadedImageState is the state of an image of Spec.PreloadImages in the nodes of the kind cluster
+// +kubebuilder:validation:Enum=Loaded;Missing;Failed
+type PreloadedImageState string
+
+const (
+	// The image is loaded into all the nodes
+	PreloadedImageLoaded PreloadedImageState = "Loaded"
+	// The image is not on the docker host, or the image archive does not exist
+	PreloadedImageMissing PreloadedImageState = "Missing"
+	// The image failed to be loaded into some nodes
+	PreloadedImageFailed PreloadedImageState = "Failed"
+)
+
+// PreloadedImage is the state of an image of Spec.PreloadImages
+type PreloadedImage struct {
+
+	// Image reference or image archive path, as in Spec.PreloadImages
+	Image string `json:"image"`
+
+	// State of the image in the nodes
+	State PreloadedImageState `json:"state"`
+
+	// Details on a missing or failed image
+	//+optional
+	Message string `json:"message,omitempty"`
+}
+
+// KindClusterPhase is the phase of the kind cluster lifecycle
+// +kubebuilder:validation:Enum=Provisioning;WaitingForControlPlane;Ready;Degraded;Upgrading;Deleting;Failed
+type KindClusterPhase string
+
+const (
+	// The kind cluster is being created
+	KindClusterPhaseProvisioning KindClusterPhase = "Provisioning"
+	// The kind cluster has been created, its control plane is not ready yet
+	KindClusterPhaseWaitingForControlPlane KindClusterPhase = "WaitingForControlPlane"
+	// The kind cluster is up and running
+	KindClusterPhaseReady KindClusterPhase = "Ready"
+	// The kind cluster was ready but is not healthy anymore: node containers stopped or missing, or health checks failing
+	KindClusterPhaseDegraded KindClusterPhase = "Degraded"
+	// The kind cluster is being moved to a new image
+	KindClusterPhaseUpgrading KindClusterPhase = "Upgrading"
+	// The kind cluster is being deleted
+	KindClusterPhaseDeleting KindClusterPhase = "Deleting"
+	// The kind cluster failed to be created
+	KindClusterPhaseFailed KindClusterPhase = "Failed"
+)
+
 // KindClusterStatus defines the observed state of KindCluster
 type KindClusterStatus struct {
 
@@ -57,15 +475,135 @@ type KindClusterStatus struct {
 	//+kubebuilder:default=false
 	Ready bool `json:"ready"`
 
-	// Number of nodes ready in the cluster
+	// Phase of the kind cluster lifecycle
+	//+optional
+	Phase KindClusterPhase `json:"phase,omitempty"`
+
+	// Number of nodes Ready in the cluster, as observed with its kubeconfig
 	//+kubebuilder:validation:Minimum=0
 	//+kubebuilder:default=0
 	Nodes int32 `json:"nodes,omitempty"`
+
+	// Name of the kind cluster backing this KindCluster
+	//+optional
+	KindClusterName string `json:"kindClusterName,omitempty"`
+
+	// Number of control plane nodes of the kind cluster, node pools included, as created or as found in the
+	// containers of an imported kind cluster
+	//+optional
+	ControlPlaneNodes int32 `json:"controlPlaneNodes,omitempty"`
+
+	// Number of worker nodes of Spec.WorkerCount the kind cluster was created or scaled to, missing ones are added
+	// back, or number of worker nodes of an imported kind cluster, as found in its containers
+	//+optional
+	WorkerNodes int32 `json:"workerNodes,omitempty"`
+
+	// Number of failed attempts to create the kind cluster, reset once it is created
+	//+optional
+	CreateAttempts int32 `json:"createAttempts,omitempty"`
+
+	// Time of the last failed attempt to create the kind cluster
+	//+optional
+	LastCreateFailureTime *metav1.Time `json:"lastCreateFailureTime,omitempty"`
+
+	// Container runtime running the kind cluster
+	//+optional
+	Runtime ContainerRuntime `json:"runtime,omitempty"`
+
+	// Name of the KindHost running the kind cluster, empty for the container host of the controller
+	//+optional
+	Host string `json:"host,omitempty"`
+
+	// Node image resolved from Spec.Image and Spec.K8sVersion, pinned by digest when known
+	//+optional
+	ResolvedImage string `json:"resolvedImage,omitempty"`
+
+	// Node image the kind cluster was created with or upgraded to, new nodes run it
+	//+optional
+	Image string `json:"image,omitempty"`
+
+	// Node image the containers of the control plane nodes run when a rolling upgrade only replaced their
+	// Kubernetes binaries with the ones of Image
+	//+optional
+	ControlPlaneImage string `json:"controlPlaneImage,omitempty"`
+
+	// Kubernetes version the kind cluster is running
+	//+optional
+	Version string `json:"version,omitempty"`
+
+	// Node image of the spec and the Kubernetes version it ships, read once from the image to check an upgrade to it
+	//+optional
+	TargetImage *NodeImageVersion `json:"targetImage,omitempty"`
+
+	// Progress of the upgrade in progress, if any
+	//+optional
+	Upgrade *KindClusterUpgradeStatus `json:"upgrade,omitempty"`
+
+	// Alpha feature gates and runtime config entries enabling alpha APIs the kind cluster runs with, comma separated
+	//+optional
+	AlphaFeatures string `json:"alphaFeatures,omitempty"`
+
+	// State of the images of Spec.PreloadImages, in the same order
+	//+optional
+	PreloadedImages []PreloadedImage `json:"preloadedImages,omitempty"`
+
+	// FailureReason will be set in the event that there is a terminal problem
+	// reconciling the KindCluster and will contain a succinct value suitable
+	// for machine interpretation.
+	//+optional
+	FailureReason *capierrors.ClusterStatusError `json:"failureReason,omitempty"`
+
+	// FailureMessage will be set in the event that there is a terminal problem
+	// reconciling the KindCluster and will contain a more verbose string suitable
+	// for logging and human consumption.
+	//+optional
+	FailureMessage *string `json:"failureMessage,omitempty"`
+
+	// Generation of the spec FailureReason was set for, a failed creation is attempted again once the spec changes
+	//+optional
+	FailureGeneration int64 `json:"failureGeneration,omitempty"`
+
+	// Conditions defines current service state of the KindCluster.
+	//+optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// KindClusterUpgradeStatus reports the progress of a kind cluster upgrade
+type KindClusterUpgradeStatus struct {
+	// Node image the kind cluster is upgraded from
+	FromImage string `json:"fromImage"`
+
+	// Node image the kind cluster is upgraded to
+	ToImage string `json:"toImage"`
+
+	// Strategy used for the upgrade
+	Strategy UpgradeStrategyType `json:"strategy"`
+
+	// Number of nodes already running the new image
+	//+optional
+	UpdatedNodes int32 `json:"updatedNodes,omitempty"`
+
+	// Number of nodes to upgrade
+	//+optional
+	TotalNodes int32 `json:"totalNodes,omitempty"`
+}
+
+// NodeImageVersion is a node image and the Kubernetes version it ships
+type NodeImageVersion struct {
+	// Node image reference
+	Image string `json:"image"`
+
+	// Kubernetes version shipped in the image, e.g. v1.25.3
+	Version string `json:"version"`
 }
 
 // KindCluster is the Schema for the kindclusters API
 // +kubebuilder:printcolumn:name="ready",type=boolean,JSONPath=`.status.ready`,description="cluster readiness"
+// +kubebuilder:printcolumn:name="phase",type=string,JSONPath=`.status.phase`,description="Kind cluster lifecycle phase"
+// +kubebuilder:printcolumn:name="reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`,description="Reason of the Ready condition"
+// +kubebuilder:printcolumn:name="alpha",type=string,JSONPath=`.status.alphaFeatures`,description="Alpha feature gates and APIs enabled"
 // +kubebuilder:printcolumn:name="created",type=date,JSONPath=`.metadata.creationTimestamp`,description="Creatiion timestamp"
+// +kubebuilder:printcolumn:name="kind-cluster",type=string,JSONPath=`.status.kindClusterName`,priority=5,description="Name of the kind cluster"
 // +kubebuilder:printcolumn:name="workers",type=integer,JSONPath=`.spec.workerCount`,priority=10,description="Number of workers nodes "
 // +kubebuilder:printcolumn:name="controlplane",type=integer,JSONPath=`.spec.controlPlaneCount`,priority=15,description="Number of nodes in control plane"
 // +kubebuilder:printcolumn:name="version",type=string,JSONPath=`.spec.k8sVersion`,priority=20,description="Kubernetes version"
@@ -81,6 +619,16 @@ type KindCluster struct {
 	Status KindClusterStatus `json:"status,omitempty"`
 }
 
+// GetConditions returns the set of conditions for this object.
+func (c *KindCluster) GetConditions() clusterv1.Conditions {
+	return c.Status.Conditions
+}
+
+// SetConditions sets the conditions on this object.
+func (c *KindCluster) SetConditions(conditions clusterv1.Conditions) {
+	c.Status.Conditions = conditions
+}
+
 //+kubebuilder:object:root=true
 
 // KindClusterList contains a list of KindCluster
@@ -0,0 +1,503 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/mbovo/cluster-api-provider-kind/pkg/featuregates"
+	"github.com/mbovo/cluster-api-provider-kind/pkg/nodeimage"
+	"github.com/pelletier/go-toml"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/yaml"
+)
+
+// SetupWebhookWithManager registers the KindCluster webhooks with the manager.
+func (c *KindCluster) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(c).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-infrastructure-cluster-x-k8s-io-v1beta1-kindcluster,mutating=true,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=kindclusters,verbs=create;update,versions=v1beta1,name=default.kindcluster.infrastructure.cluster.x-k8s.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &KindCluster{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type.
+// The image is defaulted from spec.k8sVersion, pinned by digest when kind publishes an image for that version.
+func (c *KindCluster) Default() {
+	if c.Spec.K8sVersion == "" {
+		return
+	}
+	kubeVersion, err := nodeimage.NormalizeKubeVersion(c.Spec.K8sVersion)
+	if err != nil {
+		// rejected by the validation
+		return
+	}
+	c.Spec.K8sVersion = kubeVersion
+
+	repository, tag, _ := nodeimage.SplitImage(c.Spec.Image)
+	if tag == kubeVersion {
+		return
+	}
+	if image, err := nodeimage.Resolve(c.Spec.Image, kubeVersion, nil); err == nil {
+		c.Spec.Image = image
+		return
+	}
+	// not published by kind, it may be provided by the node images override ConfigMap of the controller
+	if repository == "" {
+		repository = nodeimage.DefaultRepository
+	}
+	c.Spec.Image = fmt.Sprintf("%s:%s", repository, kubeVersion)
+}
+
+//+kubebuilder:webhook:path=/validate-infrastructure-cluster-x-k8s-io-v1beta1-kindcluster,mutating=false,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=kindclusters,verbs=create;update,versions=v1beta1,name=validation.kindcluster.infrastructure.cluster.x-k8s.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &KindCluster{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
+func (c *KindCluster) ValidateCreate() error {
+	return c.toError(c.validateSpec(nil))
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+func (c *KindCluster) ValidateUpdate(old runtime.Object) error {
+	oldCluster, ok := old.(*KindCluster)
+	if !ok {
+		return apierrors.NewBadRequest(fmt.Sprintf("expected a KindCluster but got a %T", old))
+	}
+
+	allErrs := c.validateSpec(oldCluster)
+	allErrs = append(allErrs, c.validateImmutable(oldCluster)...)
+	return c.toError(allErrs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
+func (c *KindCluster) ValidateDelete() error {
+	return nil
+}
+
+// validateSpec checks the spec fields the OpenAPI schema cannot validate.
+// On update only the fields changed from old are checked, so that objects created before a check was introduced
+// can still be updated (e.g. to remove their finalizer).
+func (c *KindCluster) validateSpec(old *KindCluster) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	if old == nil {
+		old = &KindCluster{}
+	}
+
+	// etcd needs a majority of its members to work: an even number of members tolerates no more failures
+	// than the odd number below it
+	if controlPlanes := c.controlPlaneNodes(); controlPlanes != old.controlPlaneNodes() && (c.Spec.ControlPlaneCount < 1 || controlPlanes%2 == 0) {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("controlPlaneCount"), c.Spec.ControlPlaneCount,
+			fmt.Sprintf("the %d control plane nodes (node pools included) must be an odd number to keep the etcd quorum", controlPlanes)))
+	}
+	if c.Spec.WorkerCount != old.Spec.WorkerCount && c.Spec.WorkerCount < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("workerCount"), c.Spec.WorkerCount, "must not be negative"))
+	}
+
+	if c.Spec.Image != old.Spec.Image && c.Spec.Image != "" {
+		if _, err := reference.ParseNormalizedNamed(c.Spec.Image); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("image"), c.Spec.Image, fmt.Sprintf("invalid image reference: %v", err)))
+		}
+	}
+	if c.Spec.K8sVersion != old.Spec.K8sVersion && c.Spec.K8sVersion != "" {
+		if _, err := nodeimage.NormalizeKubeVersion(c.Spec.K8sVersion); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("k8sVersion"), c.Spec.K8sVersion, "must be a Kubernetes version, e.g. v1.25.3"))
+		}
+	}
+
+	// remote hosts are reached with the docker CLI, see KindHost
+	hostChanged := !equality.Semantic.DeepEqual(c.Spec.HostRef, old.Spec.HostRef)
+	selectorChanged := !equality.Semantic.DeepEqual(c.Spec.HostSelector, old.Spec.HostSelector)
+	remote := c.Spec.HostRef != nil || c.Spec.HostSelector != nil
+	if (hostChanged || selectorChanged || c.Spec.Runtime != old.Spec.Runtime) && remote && c.Spec.Runtime != "" && c.Spec.Runtime != DockerRuntime {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("runtime"), c.Spec.Runtime, "remote hosts of spec.hostRef and spec.hostSelector run docker"))
+	}
+	if hostChanged && c.Spec.HostRef != nil && c.Spec.HostRef.Name == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("hostRef", "name"), "the name of a KindHost is required"))
+	}
+	if (hostChanged || selectorChanged) && c.Spec.HostRef != nil && c.Spec.HostSelector != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("hostSelector"), c.Spec.HostSelector, "spec.hostSelector and spec.hostRef are mutually exclusive"))
+	}
+	if selectorChanged {
+		allErrs = append(allErrs, metav1validation.ValidateLabelSelector(c.Spec.HostSelector, specPath.Child("hostSelector"))...)
+	}
+
+	// an imported kind cluster exists already: it has a name, a host and no registry mirror in its nodes
+	if c.Spec.Import && !old.Spec.Import {
+		if c.Spec.KindClusterName == "" {
+			allErrs = append(allErrs, field.Required(specPath.Child("kindClusterName"), "the name of the kind cluster to import is required"))
+		}
+		if c.Spec.HostSelector != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("hostSelector"), c.Spec.HostSelector, "an imported kind cluster runs on spec.hostRef or on the container host of the controller"))
+		}
+		if c.Spec.Registry != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("registry"), c.Spec.Registry, "the local registry cannot be added to an imported kind cluster"))
+		}
+	}
+
+	if c.Spec.Networking != old.Spec.Networking {
+		allErrs = append(allErrs, validateNetworking(specPath.Child("networking"), c.Spec.Networking)...)
+	}
+	if !equality.Semantic.DeepEqual(c.Spec.NodePools, old.Spec.NodePools) {
+		allErrs = append(allErrs, validateNodePools(specPath.Child("nodePools"), c.Spec.NodePools)...)
+	}
+	if !equality.Semantic.DeepEqual(c.Spec.KubeadmConfigPatches, old.Spec.KubeadmConfigPatches) {
+		allErrs = append(allErrs, validateKubeadmConfigPatches(specPath.Child("kubeadmConfigPatches"), c.Spec.KubeadmConfigPatches)...)
+	}
+	if !equality.Semantic.DeepEqual(c.Spec.KubeadmConfigPatchesJSON6902, old.Spec.KubeadmConfigPatchesJSON6902) {
+		allErrs = append(allErrs, validateKubeadmConfigPatchesJSON6902(specPath.Child("kubeadmConfigPatchesJSON6902"), c.Spec.KubeadmConfigPatchesJSON6902)...)
+	}
+	if !equality.Semantic.DeepEqual(c.Spec.ContainerdConfigPatches, old.Spec.ContainerdConfigPatches) {
+		allErrs = append(allErrs, validateContainerdConfigPatches(specPath.Child("containerdConfigPatches"), c.Spec.ContainerdConfigPatches)...)
+	}
+	if !equality.Semantic.DeepEqual(c.Spec.Registry, old.Spec.Registry) && c.Spec.Registry != nil && c.Spec.Registry.Image != "" {
+		if _, err := reference.ParseNormalizedNamed(c.Spec.Registry.Image); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("registry", "image"), c.Spec.Registry.Image, fmt.Sprintf("invalid image reference: %v", err)))
+		}
+	}
+	if !equality.Semantic.DeepEqual(c.Spec.PreloadImages, old.Spec.PreloadImages) {
+		for i, image := range c.Spec.PreloadImages {
+			if path.IsAbs(image) {
+				continue
+			}
+			if _, err := reference.ParseNormalizedNamed(image); err != nil {
+				allErrs = append(allErrs, field.Invalid(specPath.Child("preloadImages").Index(i), image, fmt.Sprintf("must be an image reference or an absolute image archive path: %v", err)))
+			}
+		}
+	}
+	// a new Kubernetes version may not have the gates anymore
+	if !equality.Semantic.DeepEqual(c.Spec.FeatureGates, old.Spec.FeatureGates) || c.kubeVersion() != old.kubeVersion() {
+		allErrs = append(allErrs, c.validateFeatureGates(specPath.Child("featureGates"))...)
+	}
+	if !equality.Semantic.DeepEqual(c.Spec.RuntimeConfig, old.Spec.RuntimeConfig) {
+		for key, value := range c.Spec.RuntimeConfig {
+			if err := featuregates.ValidateRuntimeConfig(key, value); err != nil {
+				allErrs = append(allErrs, field.Invalid(specPath.Child("runtimeConfig").Key(key), value, err.Error()))
+			}
+		}
+	}
+	if !equality.Semantic.DeepEqual(c.Spec.ContainerdConfigPatchesJSON6902, old.Spec.ContainerdConfigPatchesJSON6902) {
+		for i, patch := range c.Spec.ContainerdConfigPatchesJSON6902 {
+			if err := validateJSON6902Patch(patch); err != nil {
+				allErrs = append(allErrs, field.Invalid(specPath.Child("containerdConfigPatchesJSON6902").Index(i), patch, err.Error()))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// validateNetworking checks the networking the way kind does before creating a cluster
+func validateNetworking(path *field.Path, networking KindNetworking) field.ErrorList {
+	var allErrs field.ErrorList
+
+	family := networking.IPFamily
+	if family == "" {
+		family = IPv4Family
+	}
+	if networking.PodSubnet != "" {
+		if err := validateSubnet(networking.PodSubnet, family); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("podSubnet"), networking.PodSubnet, err.Error()))
+		}
+	}
+	if networking.ServiceSubnet != "" {
+		if err := validateSubnet(networking.ServiceSubnet, family); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("serviceSubnet"), networking.ServiceSubnet, err.Error()))
+		}
+	}
+	if networking.APIServerAddress != "" && net.ParseIP(networking.APIServerAddress) == nil {
+		allErrs = append(allErrs, field.Invalid(path.Child("apiServerAddress"), networking.APIServerAddress, "must be an IP address"))
+	}
+
+	return allErrs
+}
+
+// validateFeatureGates checks the feature gates exist in the Kubernetes version of the cluster,
+// unless disabled with the SkipFeatureGatesValidationAnnotation
+func (c *KindCluster) validateFeatureGates(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if _, skip := c.Annotations[SkipFeatureGatesValidationAnnotation]; skip {
+		return allErrs
+	}
+
+	kubeVersion := c.kubeVersion()
+	for name, enabled := range c.Spec.FeatureGates {
+		if err := featuregates.Validate(kubeVersion, name); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Key(name), enabled, err.Error()))
+		}
+	}
+	return allErrs
+}
+
+// kubeVersion returns the Kubernetes version of the spec, from the image tag when spec.k8sVersion is not set
+func (c *KindCluster) kubeVersion() string {
+	if c.Spec.K8sVersion != "" {
+		return c.Spec.K8sVersion
+	}
+	return nodeimage.TagKubeVersion(c.Spec.Image)
+}
+
+// validateNodePools checks the node pools can be created by kind
+func validateNodePools(fldPath *field.Path, pools []NodePool) field.ErrorList {
+	var allErrs field.ErrorList
+
+	names := map[string]bool{}
+	for i, pool := range pools {
+		poolPath := fldPath.Index(i)
+		if names[pool.Name] {
+			allErrs = append(allErrs, field.Duplicate(poolPath.Child("name"), pool.Name))
+		}
+		names[pool.Name] = true
+
+		if pool.Image != "" {
+			if _, err := reference.ParseNormalizedNamed(pool.Image); err != nil {
+				allErrs = append(allErrs, field.Invalid(poolPath.Child("image"), pool.Image, fmt.Sprintf("invalid image reference: %v", err)))
+			}
+		}
+		// paths are resolved by the container runtime on the host, not relative to the controller
+		for j, m := range pool.ExtraMounts {
+			if !path.IsAbs(m.HostPath) {
+				allErrs = append(allErrs, field.Invalid(poolPath.Child("extraMounts").Index(j).Child("hostPath"), m.HostPath, "must be an absolute path"))
+			}
+			if !path.IsAbs(m.ContainerPath) {
+				allErrs = append(allErrs, field.Invalid(poolPath.Child("extraMounts").Index(j).Child("containerPath"), m.ContainerPath, "must be an absolute path"))
+			}
+		}
+		for j, pm := range pool.ExtraPortMappings {
+			if pm.ListenAddress != "" && net.ParseIP(pm.ListenAddress) == nil {
+				allErrs = append(allErrs, field.Invalid(poolPath.Child("extraPortMappings").Index(j).Child("listenAddress"), pm.ListenAddress, "must be an IP address"))
+			}
+			if pm.HostPort != 0 && pool.Replicas > 1 {
+				allErrs = append(allErrs, field.Invalid(poolPath.Child("extraPortMappings").Index(j).Child("hostPort"), pm.HostPort,
+					"a host port can be mapped to a single node, set replicas to 1"))
+			}
+		}
+		allErrs = append(allErrs, validateKubeadmConfigPatches(poolPath.Child("kubeadmConfigPatches"), pool.KubeadmConfigPatches)...)
+		allErrs = append(allErrs, validateKubeadmConfigPatchesJSON6902(poolPath.Child("kubeadmConfigPatchesJSON6902"), pool.KubeadmConfigPatchesJSON6902)...)
+	}
+
+	return allErrs
+}
+
+// validateKubeadmConfigPatches checks every document of the patches is a YAML object matching a kubeadm configuration kind
+func validateKubeadmConfigPatches(fldPath *field.Path, patches []string) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, patch := range patches {
+		reader := utilyaml.NewYAMLReader(bufio.NewReader(strings.NewReader(patch)))
+		for {
+			doc, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				allErrs = append(allErrs, field.Invalid(fldPath.Index(i), patch, fmt.Sprintf("invalid YAML: %v", err)))
+				break
+			}
+			if len(bytes.TrimSpace(doc)) == 0 {
+				continue
+			}
+			var meta struct {
+				Kind string `json:"kind"`
+			}
+			if err := yaml.Unmarshal(doc, &meta); err != nil {
+				allErrs = append(allErrs, field.Invalid(fldPath.Index(i), patch, fmt.Sprintf("invalid YAML object: %v", err)))
+				break
+			}
+			// patches are matched on the kind of the kubeadm configuration objects, a patch without kind is never applied
+			if meta.Kind == "" {
+				allErrs = append(allErrs, field.Invalid(fldPath.Index(i), patch, "must set the kind of the kubeadm configuration to patch"))
+				break
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateKubeadmConfigPatchesJSON6902 checks the patches target a kubeadm configuration kind and parse as JSON 6902 patches
+func validateKubeadmConfigPatchesJSON6902(fldPath *field.Path, patches []PatchJSON6902) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, patch := range patches {
+		if patch.Kind == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Index(i).Child("kind"), "must set the kind of the kubeadm configuration to patch"))
+		}
+		if patch.Version == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Index(i).Child("version"), "must set the version of the kubeadm configuration to patch"))
+		}
+		if err := validateJSON6902Patch(patch.Patch); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i).Child("patch"), patch.Patch, err.Error()))
+		}
+	}
+	return allErrs
+}
+
+// validateJSON6902Patch checks the patch, in JSON or YAML, is a JSON 6902 patch
+func validateJSON6902Patch(patch string) error {
+	raw, err := yaml.YAMLToJSON([]byte(patch))
+	if err != nil {
+		return fmt.Errorf("invalid YAML: %v", err)
+	}
+	if _, err := jsonpatch.DecodePatch(raw); err != nil {
+		return fmt.Errorf("invalid JSON 6902 patch: %v", err)
+	}
+	return nil
+}
+
+// validateContainerdConfigPatches checks the patches are TOML documents
+func validateContainerdConfigPatches(fldPath *field.Path, patches []string) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, patch := range patches {
+		if _, err := toml.Load(patch); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i), patch, fmt.Sprintf("invalid TOML: %v", err)))
+		}
+	}
+	return allErrs
+}
+
+// validateSubnet checks the subnet is a CIDR of the given IP family, a comma separated IPv4 and IPv6 pair for dual-stack
+func validateSubnet(subnet string, family IPFamily) error {
+	var ipv4, ipv6 int
+	for _, cidr := range strings.Split(subnet, ",") {
+		ip, _, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			return fmt.Errorf("invalid CIDR %q", cidr)
+		}
+		if ip.To4() != nil {
+			ipv4++
+		} else {
+			ipv6++
+		}
+	}
+
+	switch family {
+	case IPv4Family:
+		if ipv4 != 1 || ipv6 != 0 {
+			return fmt.Errorf("must be one IPv4 CIDR")
+		}
+	case IPv6Family:
+		if ipv4 != 0 || ipv6 != 1 {
+			return fmt.Errorf("must be one IPv6 CIDR")
+		}
+	case DualStackFamily:
+		if ipv4 != 1 || ipv6 != 1 {
+			return fmt.Errorf("must be an IPv4 and an IPv6 CIDR separated by a comma")
+		}
+	}
+	return nil
+}
+
+// validateImmutable rejects changes to the fields the kind cluster cannot be updated with
+func (c *KindCluster) validateImmutable(old *KindCluster) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	// the kind cluster name is derived from namespace and name when not set, never rename a cluster
+	if c.Spec.KindClusterName != old.Spec.KindClusterName {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("kindClusterName"), "field is immutable"))
+	}
+
+	// an imported kind cluster was not created by the controller, a created one cannot be given back
+	if c.Spec.Import != old.Spec.Import {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("import"), "field is immutable"))
+	}
+
+	// the other fields are immutable once the kind cluster is created, they can be fixed after a failed creation
+	if old.Status.Phase == KindClusterPhaseFailed {
+		return allErrs
+	}
+
+	// kind cannot add nor remove control plane nodes of an existing cluster
+	if c.Spec.ControlPlaneCount != old.Spec.ControlPlaneCount {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("controlPlaneCount"), "field is immutable"))
+	}
+	// kind cannot change the networking of an existing cluster
+	if c.Spec.Networking != old.Spec.Networking {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("networking"), "field is immutable"))
+	}
+	// node pools take the first worker indexes at creation, they cannot be resized afterwards
+	if !equality.Semantic.DeepEqual(c.Spec.NodePools, old.Spec.NodePools) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("nodePools"), "field is immutable"))
+	}
+	// config patches are applied by kind when creating the nodes, changing them would leave nodes configured differently
+	if !equality.Semantic.DeepEqual(c.Spec.KubeadmConfigPatches, old.Spec.KubeadmConfigPatches) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("kubeadmConfigPatches"), "field is immutable"))
+	}
+	if !equality.Semantic.DeepEqual(c.Spec.KubeadmConfigPatchesJSON6902, old.Spec.KubeadmConfigPatchesJSON6902) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("kubeadmConfigPatchesJSON6902"), "field is immutable"))
+	}
+	if !equality.Semantic.DeepEqual(c.Spec.ContainerdConfigPatches, old.Spec.ContainerdConfigPatches) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("containerdConfigPatches"), "field is immutable"))
+	}
+	if !equality.Semantic.DeepEqual(c.Spec.ContainerdConfigPatchesJSON6902, old.Spec.ContainerdConfigPatchesJSON6902) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("containerdConfigPatchesJSON6902"), "field is immutable"))
+	}
+	// feature gates and runtime config are set in the kubeadm configuration of the cluster at creation
+	if !equality.Semantic.DeepEqual(c.Spec.FeatureGates, old.Spec.FeatureGates) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("featureGates"), "field is immutable"))
+	}
+	if !equality.Semantic.DeepEqual(c.Spec.RuntimeConfig, old.Spec.RuntimeConfig) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("runtimeConfig"), "field is immutable"))
+	}
+	if old.Spec.Runtime != c.Spec.Runtime {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("runtime"), "field is immutable"))
+	}
+	if !equality.Semantic.DeepEqual(c.Spec.HostRef, old.Spec.HostRef) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("hostRef"), "field is immutable"))
+	}
+	// the registry is a mirror in the containerd configuration of the nodes
+	if !equality.Semantic.DeepEqual(c.Spec.Registry, old.Spec.Registry) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("registry"), "field is immutable"))
+	}
+
+	return allErrs
+}
+
+// controlPlaneNodes returns the number of control plane nodes of the cluster, node pools included
+func (c *KindCluster) controlPlaneNodes() int32 {
+	count := c.Spec.ControlPlaneCount
+	for _, pool := range c.Spec.NodePools {
+		if pool.Role == ControlPlaneRole {
+			count += pool.Replicas
+		}
+	}
+	return count
+}
+
+// toError wraps the validation errors in an Invalid API error, nil if there are none
+func (c *KindCluster) toError(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("KindCluster").GroupKind(), c.Name, allErrs)
+}
@@ -0,0 +1,450 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestKindClusterDefault(t *testing.T) {
+	tests := []struct {
+		name        string
+		spec        KindClusterSpec
+		wantImage   string
+		wantVersion string
+	}{
+		{
+			name:      "no version keeps the image",
+			spec:      KindClusterSpec{Image: "kindest/node:v1.24.7"},
+			wantImage: "kindest/node:v1.24.7",
+		},
+		{
+			name:        "image resolved from the version, pinned by digest",
+			spec:        KindClusterSpec{Image: "kindest/node:v1.25.2@sha256:9be91e9e9cdf116809841fc77ebdb8845443c4c72fe5218f3ae9eb57fdb4bace", K8sVersion: "1.25.3"},
+			wantImage:   "kindest/node:v1.25.3@sha256:f52781bc0d7a19fb6c405c2af83abfeb311f130707a0e219175677e366cc45d1",
+			wantVersion: "v1.25.3",
+		},
+		{
+			name:        "repository with a registry port is kept",
+			spec:        KindClusterSpec{Image: "localhost:5000/kindest/node:v1.25.2", K8sVersion: "v1.24.7"},
+			wantImage:   "localhost:5000/kindest/node:v1.24.7@sha256:577c630ce8e509131eab1aea12c022190978dd2f745aac5eb1fe65c0807eb315",
+			wantVersion: "v1.24.7",
+		},
+		{
+			name:        "image matching the version is kept",
+			spec:        KindClusterSpec{Image: "example.com/node:v1.25.3", K8sVersion: "v1.25.3"},
+			wantImage:   "example.com/node:v1.25.3",
+			wantVersion: "v1.25.3",
+		},
+		{
+			name:        "version not published by kind is tagged",
+			spec:        KindClusterSpec{Image: "localhost:5000/kindest/node", K8sVersion: "v1.26.0"},
+			wantImage:   "localhost:5000/kindest/node:v1.26.0",
+			wantVersion: "v1.26.0",
+		},
+		{
+			name:        "invalid version is left to the validation",
+			spec:        KindClusterSpec{Image: "kindest/node:v1.25.3", K8sVersion: "latest"},
+			wantImage:   "kindest/node:v1.25.3",
+			wantVersion: "latest",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c := &KindCluster{Spec: tt.spec}
+			c.Default()
+			g.Expect(c.Spec.Image).To(Equal(tt.wantImage))
+			g.Expect(c.Spec.K8sVersion).To(Equal(tt.wantVersion))
+		})
+	}
+}
+
+func TestKindClusterValidateCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    KindClusterSpec
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			spec: KindClusterSpec{ControlPlaneCount: 3, WorkerCount: 2, Image: "localhost:5000/kindest/node:v1.25.3", K8sVersion: "v1.25.3"},
+		},
+		{
+			name:    "even control plane count",
+			spec:    KindClusterSpec{ControlPlaneCount: 2},
+			wantErr: true,
+		},
+		{
+			name:    "negative worker count",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, WorkerCount: -1},
+			wantErr: true,
+		},
+		{
+			name:    "invalid image",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, Image: "kindest/Node:v1.25.3"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid version",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, K8sVersion: "1.25"},
+			wantErr: true,
+		},
+		{
+			name: "dual-stack networking",
+			spec: KindClusterSpec{ControlPlaneCount: 1, Networking: KindNetworking{IPFamily: DualStackFamily, PodSubnet: "10.244.0.0/16,fd00:10:244::/56", APIServerAddress: "0.0.0.0", APIServerPort: 6443}},
+		},
+		{
+			name:    "invalid pod subnet",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, Networking: KindNetworking{PodSubnet: "10.244.0.0"}},
+			wantErr: true,
+		},
+		{
+			name:    "service subnet of another IP family",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, Networking: KindNetworking{IPFamily: IPv6Family, ServiceSubnet: "10.96.0.0/16"}},
+			wantErr: true,
+		},
+		{
+			name:    "single-stack subnet for a dual-stack cluster",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, Networking: KindNetworking{IPFamily: DualStackFamily, PodSubnet: "10.244.0.0/16"}},
+			wantErr: true,
+		},
+		{
+			name: "ingress node pool",
+			spec: KindClusterSpec{ControlPlaneCount: 1, NodePools: []NodePool{{
+				Name:              "ingress",
+				Role:              WorkerRole,
+				Replicas:          1,
+				Labels:            map[string]string{"ingress-ready": "true"},
+				ExtraPortMappings: []PortMapping{{ContainerPort: 80, HostPort: 80}, {ContainerPort: 443, HostPort: 443, ListenAddress: "127.0.0.1"}},
+				ExtraMounts:       []Mount{{HostPath: "/srv/data", ContainerPath: "/data", ReadOnly: true}},
+			}}},
+		},
+		{
+			name:    "even control plane nodes with a node pool",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, NodePools: []NodePool{{Name: "cp", Role: ControlPlaneRole, Replicas: 1}}},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate node pool",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, NodePools: []NodePool{{Name: "a", Role: WorkerRole, Replicas: 1}, {Name: "a", Role: WorkerRole, Replicas: 2}}},
+			wantErr: true,
+		},
+		{
+			name:    "relative host path",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, NodePools: []NodePool{{Name: "a", Role: WorkerRole, Replicas: 1, ExtraMounts: []Mount{{HostPath: "data", ContainerPath: "/data"}}}}},
+			wantErr: true,
+		},
+		{
+			name:    "host port mapped to several nodes",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, NodePools: []NodePool{{Name: "a", Role: WorkerRole, Replicas: 2, ExtraPortMappings: []PortMapping{{ContainerPort: 80, HostPort: 8080}}}}},
+			wantErr: true,
+		},
+		{
+			name: "config patches",
+			spec: KindClusterSpec{
+				ControlPlaneCount: 1,
+				KubeadmConfigPatches: []string{`kind: ClusterConfiguration
+apiServer:
+  extraArgs:
+    enable-admission-plugins: NodeRestriction,PodSecurity
+---
+kind: InitConfiguration
+nodeRegistration:
+  kubeletExtraArgs:
+    v: "4"`},
+				KubeadmConfigPatchesJSON6902: []PatchJSON6902{{Group: "kubeadm.k8s.io", Version: "v1beta3", Kind: "ClusterConfiguration", Patch: `- op: add
+  path: /apiServer/certSANs/-
+  value: my-hostname`}},
+				ContainerdConfigPatches: []string{`[plugins."io.containerd.grpc.v1.cri".registry.mirrors."localhost:5000"]
+  endpoint = ["http://kind-registry:5000"]`},
+			},
+		},
+		{
+			name:    "kubeadm config patch without kind",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, KubeadmConfigPatches: []string{"apiServer:\n  extraArgs:\n    v: \"4\""}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid kubeadm config patch of a node pool",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, NodePools: []NodePool{{Name: "a", Role: WorkerRole, Replicas: 1, KubeadmConfigPatches: []string{"kind: [JoinConfiguration"}}}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid JSON 6902 patch",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, KubeadmConfigPatchesJSON6902: []PatchJSON6902{{Version: "v1beta3", Kind: "ClusterConfiguration", Patch: `{"op": "add"}`}}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid containerd config patch",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, ContainerdConfigPatches: []string{`[plugins."io.containerd.grpc.v1.cri"`}},
+			wantErr: true,
+		},
+		{
+			name: "alpha feature gate and API",
+			spec: KindClusterSpec{
+				ControlPlaneCount: 1,
+				K8sVersion:        "v1.25.3",
+				FeatureGates:      map[string]bool{"APIServerTracing": true},
+				RuntimeConfig:     map[string]string{"api/alpha": "true"},
+			},
+		},
+		{
+			name:    "unknown feature gate",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, K8sVersion: "v1.25.3", FeatureGates: map[string]bool{"NoSuchGate": true}},
+			wantErr: true,
+		},
+		{
+			name:    "feature gate removed in the Kubernetes version of the image",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, Image: "kindest/node:v1.25.3", FeatureGates: map[string]bool{"WarningHeaders": true}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid runtime config",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, RuntimeConfig: map[string]string{"api": "yes"}},
+			wantErr: true,
+		},
+		{
+			name: "local registry",
+			spec: KindClusterSpec{ControlPlaneCount: 1, Registry: &KindRegistry{Image: "registry:2", HostPort: 5001}},
+		},
+		{
+			name:    "invalid registry image",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, Registry: &KindRegistry{Image: "Registry:2", HostPort: 5001}},
+			wantErr: true,
+		},
+		{
+			name: "preload images and image archives",
+			spec: KindClusterSpec{ControlPlaneCount: 1, PreloadImages: []string{"nginx:1.23", "ghcr.io/org/app@sha256:9be91e9e9cdf116809841fc77ebdb8845443c4c72fe5218f3ae9eb57fdb4bace", "/images/app.tar"}},
+		},
+		{
+			name:    "invalid preload image",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, PreloadImages: []string{"./images/app.tar"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid API server address",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, Networking: KindNetworking{APIServerAddress: "localhost"}},
+			wantErr: true,
+		},
+		{
+			name: "remote host",
+			spec: KindClusterSpec{ControlPlaneCount: 1, HostRef: &corev1.LocalObjectReference{Name: "build-1"}},
+		},
+		{
+			name:    "remote host without name",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, HostRef: &corev1.LocalObjectReference{}},
+			wantErr: true,
+		},
+		{
+			name:    "remote host with podman",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, Runtime: PodmanRuntime, HostRef: &corev1.LocalObjectReference{Name: "build-1"}},
+			wantErr: true,
+		},
+		{
+			name: "host selector",
+			spec: KindClusterSpec{ControlPlaneCount: 1, HostSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"pool": "ci"}}},
+		},
+		{
+			name:    "host selector and host",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, HostRef: &corev1.LocalObjectReference{Name: "build-1"}, HostSelector: &metav1.LabelSelector{}},
+			wantErr: true,
+		},
+		{
+			name: "invalid host selector",
+			spec: KindClusterSpec{ControlPlaneCount: 1, HostSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "pool", Operator: metav1.LabelSelectorOpIn},
+			}}},
+			wantErr: true,
+		},
+		{
+			name: "import",
+			spec: KindClusterSpec{ControlPlaneCount: 1, KindClusterName: "dev", Import: true, DeletionPolicy: OrphanDeletionPolicy},
+		},
+		{
+			name:    "import without kind cluster name",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, Import: true},
+			wantErr: true,
+		},
+		{
+			name:    "import with a local registry",
+			spec:    KindClusterSpec{ControlPlaneCount: 1, KindClusterName: "dev", Import: true, Registry: &KindRegistry{Image: "registry:2", HostPort: 5001}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c := &KindCluster{Spec: tt.spec}
+			if tt.wantErr {
+				g.Expect(c.ValidateCreate()).NotTo(Succeed())
+			} else {
+				g.Expect(c.ValidateCreate()).To(Succeed())
+			}
+		})
+	}
+}
+
+func TestKindClusterValidateUpdate(t *testing.T) {
+	tests := []struct {
+		name      string
+		oldSpec   KindClusterSpec
+		oldStatus KindClusterStatus
+		newSpec   KindClusterSpec
+		wantErr   bool
+	}{
+		{
+			name:    "scale workers",
+			oldSpec: KindClusterSpec{ControlPlaneCount: 1, WorkerCount: 1},
+			newSpec: KindClusterSpec{ControlPlaneCount: 1, WorkerCount: 3},
+		},
+		{
+			name:    "scale control plane",
+			oldSpec: KindClusterSpec{ControlPlaneCount: 1, WorkerCount: 1},
+			newSpec: KindClusterSpec{ControlPlaneCount: 3, WorkerCount: 1},
+			wantErr: true,
+		},
+		{
+			name:    "change kind cluster name",
+			oldSpec: KindClusterSpec{ControlPlaneCount: 1, KindClusterName: "a"},
+			newSpec: KindClusterSpec{ControlPlaneCount: 1, KindClusterName: "b"},
+			wantErr: true,
+		},
+		{
+			name:    "set kind cluster name",
+			oldSpec: KindClusterSpec{ControlPlaneCount: 1},
+			newSpec: KindClusterSpec{ControlPlaneCount: 1, KindClusterName: "b"},
+			wantErr: true,
+		},
+		{
+			name:    "change networking",
+			oldSpec: KindClusterSpec{ControlPlaneCount: 1},
+			newSpec: KindClusterSpec{ControlPlaneCount: 1, Networking: KindNetworking{KubeProxyMode: IPVSProxyMode}},
+			wantErr: true,
+		},
+		{
+			name:    "resize node pool",
+			oldSpec: KindClusterSpec{ControlPlaneCount: 1, NodePools: []NodePool{{Name: "a", Role: WorkerRole, Replicas: 1}}},
+			newSpec: KindClusterSpec{ControlPlaneCount: 1, NodePools: []NodePool{{Name: "a", Role: WorkerRole, Replicas: 2}}},
+			wantErr: true,
+		},
+		{
+			name:    "change containerd config patches",
+			oldSpec: KindClusterSpec{ControlPlaneCount: 1},
+			newSpec: KindClusterSpec{ControlPlaneCount: 1, ContainerdConfigPatches: []string{`[plugins."io.containerd.grpc.v1.cri".containerd]
+  snapshotter = "native"`}},
+			wantErr: true,
+		},
+		{
+			name:    "change container runtime",
+			oldSpec: KindClusterSpec{ControlPlaneCount: 1, Runtime: DockerRuntime},
+			newSpec: KindClusterSpec{ControlPlaneCount: 1, Runtime: PodmanRuntime},
+			wantErr: true,
+		},
+		{
+			name:    "move to another host",
+			oldSpec: KindClusterSpec{ControlPlaneCount: 1, HostRef: &corev1.LocalObjectReference{Name: "build-1"}},
+			newSpec: KindClusterSpec{ControlPlaneCount: 1, HostRef: &corev1.LocalObjectReference{Name: "build-2"}},
+			wantErr: true,
+		},
+		{
+			name:    "import a created cluster",
+			oldSpec: KindClusterSpec{ControlPlaneCount: 1, KindClusterName: "dev"},
+			newSpec: KindClusterSpec{ControlPlaneCount: 1, KindClusterName: "dev", Import: true},
+			wantErr: true,
+		},
+		{
+			name:    "orphan an imported cluster",
+			oldSpec: KindClusterSpec{ControlPlaneCount: 1, KindClusterName: "dev", Import: true},
+			newSpec: KindClusterSpec{ControlPlaneCount: 1, KindClusterName: "dev", Import: true, DeletionPolicy: OrphanDeletionPolicy},
+		},
+		{
+			name:    "add local registry",
+			oldSpec: KindClusterSpec{ControlPlaneCount: 1},
+			newSpec: KindClusterSpec{ControlPlaneCount: 1, Registry: &KindRegistry{Image: "registry:2", HostPort: 5001}},
+			wantErr: true,
+		},
+		{
+			name:    "change feature gates",
+			oldSpec: KindClusterSpec{ControlPlaneCount: 1, K8sVersion: "v1.25.3"},
+			newSpec: KindClusterSpec{ControlPlaneCount: 1, K8sVersion: "v1.25.3", FeatureGates: map[string]bool{"APIServerTracing": true}},
+			wantErr: true,
+		},
+		{
+			name:    "upgrade to a version without the feature gate",
+			oldSpec: KindClusterSpec{ControlPlaneCount: 1, K8sVersion: "v1.23.13", FeatureGates: map[string]bool{"WarningHeaders": true}},
+			newSpec: KindClusterSpec{ControlPlaneCount: 1, K8sVersion: "v1.24.7", FeatureGates: map[string]bool{"WarningHeaders": true}},
+			wantErr: true,
+		},
+		{
+			name:      "fix the API server port after a failed creation",
+			oldSpec:   KindClusterSpec{ControlPlaneCount: 1, Networking: KindNetworking{APIServerPort: 6443}},
+			oldStatus: KindClusterStatus{Phase: KindClusterPhaseFailed},
+			newSpec:   KindClusterSpec{ControlPlaneCount: 1, Networking: KindNetworking{APIServerPort: 7443}},
+		},
+		{
+			name:      "change the API server port of a created cluster",
+			oldSpec:   KindClusterSpec{ControlPlaneCount: 1, Networking: KindNetworking{APIServerPort: 6443}},
+			oldStatus: KindClusterStatus{Phase: KindClusterPhaseReady},
+			newSpec:   KindClusterSpec{ControlPlaneCount: 1, Networking: KindNetworking{APIServerPort: 7443}},
+			wantErr:   true,
+		},
+		{
+			name:      "move to another host after a failed creation",
+			oldSpec:   KindClusterSpec{ControlPlaneCount: 1, HostRef: &corev1.LocalObjectReference{Name: "build-1"}},
+			oldStatus: KindClusterStatus{Phase: KindClusterPhaseFailed},
+			newSpec:   KindClusterSpec{ControlPlaneCount: 1, HostRef: &corev1.LocalObjectReference{Name: "build-2"}},
+		},
+		{
+			name:      "rename after a failed creation",
+			oldSpec:   KindClusterSpec{ControlPlaneCount: 1, KindClusterName: "a"},
+			oldStatus: KindClusterStatus{Phase: KindClusterPhaseFailed},
+			newSpec:   KindClusterSpec{ControlPlaneCount: 1, KindClusterName: "b"},
+			wantErr:   true,
+		},
+		{
+			name:    "even control plane count",
+			oldSpec: KindClusterSpec{ControlPlaneCount: 1},
+			newSpec: KindClusterSpec{ControlPlaneCount: 2},
+			wantErr: true,
+		},
+		{
+			name:    "even control plane count created before the validation",
+			oldSpec: KindClusterSpec{ControlPlaneCount: 2, WorkerCount: 0},
+			newSpec: KindClusterSpec{ControlPlaneCount: 2, WorkerCount: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			oldCluster := &KindCluster{Spec: tt.oldSpec, Status: tt.oldStatus}
+			newCluster := &KindCluster{Spec: tt.newSpec}
+			if tt.wantErr {
+				g.Expect(newCluster.ValidateUpdate(oldCluster)).NotTo(Succeed())
+			} else {
+				g.Expect(newCluster.ValidateUpdate(oldCluster)).To(Succeed())
+			}
+		})
+	}
+}
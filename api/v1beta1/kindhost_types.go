@@ -0,0 +1,144 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	// Be sure to be called before object removal from the apiserver.
+	KindHostFinalizer = "kindhost.infrastructure.cluster.x-k8s.io"
+)
+
+// Keys of the Secret of a KindHost
+const (
+	// Endpoint of the docker daemon, as in DOCKER_HOST: tcp://<host>:<port> or ssh://[<user>@]<host>[:<port>]
+	KindHostEndpointKey = "host"
+	// CA certificate verifying the docker daemon of a tcp endpoint, enables TLS
+	KindHostCACertKey = "ca.crt"
+	// Client certificate and key of a tcp endpoint with TLS
+	KindHostClientCertKey = "tls.crt"
+	KindHostClientKeyKey  = "tls.key"
+	// Private key of an ssh endpoint
+	KindHostSSHPrivateKeyKey = "ssh-privatekey"
+	// Known hosts of an ssh endpoint, the host key is accepted on first use when missing
+	KindHostKnownHostsKey = "known_hosts"
+)
+
+// KindHostSpec defines a remote docker daemon kind clusters can be created on
+type KindHostSpec struct {
+
+	// Secret in the namespace of the KindHost holding the endpoint of the docker daemon and its credentials,
+	// see the KindHost*Key constants for its keys
+	SecretRef corev1.LocalObjectReference `json:"secretRef"`
+
+	// Address the API servers of the kind clusters are reached at, defaults to the host of the endpoint.
+	// The API servers of kind clusters without spec.networking.apiServerAddress listen on all the addresses of the host.
+	//+optional
+	Address string `json:"address,omitempty"`
+
+	// Maximum number of kind clusters scheduled on the host, unlimited when not set
+	//+optional
+	//+kubebuilder:validation:Minimum=0
+	MaxClusters *int32 `json:"maxClusters,omitempty"`
+
+	// CPU and memory a kind node is expected to use, defaults to 1 CPU and 2Gi of memory. Every running container
+	// of the host is accounted for as a node when checking whether a kind cluster fits on the host.
+	//+optional
+	NodeResources corev1.ResourceList `json:"nodeResources,omitempty"`
+}
+
+// KindHostCapacity is the capacity of the docker daemon of a KindHost
+type KindHostCapacity struct {
+
+	// Number of CPUs of the host
+	CPUs int32 `json:"cpus"`
+
+	// Memory of the host
+	Memory resource.Quantity `json:"memory"`
+
+	// Containers running on the host, kind nodes and others
+	RunningContainers int32 `json:"runningContainers"`
+}
+
+// KindHostStatus defines the observed state of KindHost
+type KindHostStatus struct {
+
+	// Host readiness, the docker daemon answers with the credentials of the Secret
+	//+kubebuilder:default=false
+	Ready bool `json:"ready"`
+
+	// Version of the docker daemon
+	//+optional
+	Version string `json:"version,omitempty"`
+
+	// Capacity of the host, as last reported by the docker daemon
+	//+optional
+	Capacity *KindHostCapacity `json:"capacity,omitempty"`
+
+	// Number of KindClusters running on the host
+	//+optional
+	Clusters int32 `json:"clusters,omitempty"`
+
+	// Conditions defines current service state of the KindHost.
+	//+optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// KindHost is the Schema for the kindhosts API
+// +kubebuilder:printcolumn:name="ready",type=boolean,JSONPath=`.status.ready`,description="Host readiness"
+// +kubebuilder:printcolumn:name="version",type=string,JSONPath=`.status.version`,description="Version of the docker daemon"
+// +kubebuilder:printcolumn:name="clusters",type=integer,JSONPath=`.status.clusters`,description="Number of KindClusters running on the host"
+// +kubebuilder:printcolumn:name="containers",type=integer,JSONPath=`.status.capacity.runningContainers`,description="Containers running on the host"
+// +kubebuilder:printcolumn:name="created",type=date,JSONPath=`.metadata.creationTimestamp`,description="Creation timestamp"
+// +kubebuilder:resource:shortName={kh}
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+type KindHost struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KindHostSpec   `json:"spec,omitempty"`
+	Status KindHostStatus `json:"status,omitempty"`
+}
+
+// GetConditions returns the set of conditions for this object.
+func (h *KindHost) GetConditions() clusterv1.Conditions {
+	return h.Status.Conditions
+}
+
+// SetConditions sets the conditions on this object.
+func (h *KindHost) SetConditions(conditions clusterv1.Conditions) {
+	h.Status.Conditions = conditions
+}
+
+//+kubebuilder:object:root=true
+
+// KindHostList contains a list of KindHost
+type KindHostList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KindHost `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KindHost{}, &KindHostList{})
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+)
+
+const (
+	// Be sure to be called before object removal from the apiserver.
+	KindMachineFinalizer = "kindmachine.infrastructure.cluster.x-k8s.io"
+
+	// Label set on the node container of a KindMachine, holding the UID of the KindMachine owning it.
+	KindMachineUIDLabel = "infrastructure.cluster.x-k8s.io/kindmachine-uid"
+)
+
+// KindMachineSpec defines the desired state of KindMachine
+type KindMachineSpec struct {
+
+	// ProviderID of the kind node backing this KindMachine, in the form kind://<runtime>/<kind cluster>/<node>.
+	// Set by the controller once the node has been created.
+	//+optional
+	ProviderID *string `json:"providerID,omitempty"`
+
+	// KIND image to use, defaults to the image for the Machine version if set, to the image of the KindCluster otherwise
+	//+optional
+	Image string `json:"image,omitempty"`
+}
+
+// KindMachineStatus defines the observed state of KindMachine
+type KindMachineStatus struct {
+
+	// Machine readiness, the kind node has been created and joined to the kind cluster
+	//+kubebuilder:default=false
+	Ready bool `json:"ready"`
+
+	// Name of the kind node (and of its container) backing this KindMachine
+	//+optional
+	NodeName string `json:"nodeName,omitempty"`
+
+	// Addresses of the kind node
+	//+optional
+	Addresses []clusterv1.MachineAddress `json:"addresses,omitempty"`
+
+	// FailureReason will be set in the event that there is a terminal problem
+	// reconciling the KindMachine and will contain a succinct value suitable
+	// for machine interpretation.
+	//+optional
+	FailureReason *capierrors.MachineStatusError `json:"failureReason,omitempty"`
+
+	// FailureMessage will be set in the event that there is a terminal problem
+	// reconciling the KindMachine and will contain a more verbose string suitable
+	// for logging and human consumption.
+	//+optional
+	FailureMessage *string `json:"failureMessage,omitempty"`
+
+	// Conditions defines current service state of the KindMachine.
+	//+optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// KindMachine is the Schema for the kindmachines API
+// +kubebuilder:printcolumn:name="cluster",type=string,JSONPath=`.metadata.labels.cluster\.x-k8s\.io/cluster-name`,description="Cluster to which this KindMachine belongs"
+// +kubebuilder:printcolumn:name="ready",type=boolean,JSONPath=`.status.ready`,description="Machine readiness"
+// +kubebuilder:printcolumn:name="node",type=string,JSONPath=`.status.nodeName`,description="Name of the kind node"
+// +kubebuilder:printcolumn:name="providerid",type=string,JSONPath=`.spec.providerID`,description="Provider ID"
+// +kubebuilder:printcolumn:name="machine",type=string,JSONPath=`.metadata.ownerReferences[?(@.kind=="Machine")].name`,description="Machine object which owns this KindMachine"
+// +kubebuilder:printcolumn:name="created",type=date,JSONPath=`.metadata.creationTimestamp`,description="Creation timestamp"
+// +kubebuilder:resource:shortName={km}
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+type KindMachine struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KindMachineSpec   `json:"spec,omitempty"`
+	Status KindMachineStatus `json:"status,omitempty"`
+}
+
+// GetConditions returns the set of conditions for this object.
+func (m *KindMachine) GetConditions() clusterv1.Conditions {
+	return m.Status.Conditions
+}
+
+// SetConditions sets the conditions on this object.
+func (m *KindMachine) SetConditions(conditions clusterv1.Conditions) {
+	m.Status.Conditions = conditions
+}
+
+//+kubebuilder:object:root=true
+
+// KindMachineList contains a list of KindMachine
+type KindMachineList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KindMachine `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KindMachine{}, &KindMachineList{})
+}
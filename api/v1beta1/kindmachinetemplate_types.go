@@ -0,0 +1,61 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// KindMachineTemplateSpec defines the desired state of KindMachineTemplate
+type KindMachineTemplateSpec struct {
+	Template KindMachineTemplateResource `json:"template"`
+}
+
+// KindMachineTemplateResource describes the data needed to create a KindMachine from a template
+type KindMachineTemplateResource struct {
+	// Standard object's metadata.
+	// More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#metadata
+	//+optional
+	ObjectMeta clusterv1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec is the specification of the desired behavior of the machine.
+	Spec KindMachineSpec `json:"spec"`
+}
+
+// KindMachineTemplate is the Schema for the kindmachinetemplates API
+// +kubebuilder:resource:shortName={kmt}
+// +kubebuilder:object:root=true
+type KindMachineTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec KindMachineTemplateSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// KindMachineTemplateList contains a list of KindMachineTemplate
+type KindMachineTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KindMachineTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KindMachineTemplate{}, &KindMachineTemplateList{})
+}
@@ -19,19 +19,25 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	infrastructurev1beta1 "github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/mbovo/cluster-api-provider-kind/pkg/featuregates"
 	"github.com/mbovo/cluster-api-provider-kind/pkg/kind"
+	"github.com/mbovo/cluster-api-provider-kind/pkg/nodeimage"
 	"github.com/pkg/errors"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/version"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/cluster-api/util/predicates"
 
@@ -55,8 +61,22 @@ type KindClusterReconciler struct {
 	Recorder   record.EventRecorder
 	patcher    *patch.Helper
 	kindHelper kind.KindHelper
+	operations *operationTracker
+	scheduled  *scheduledHosts
+
+	// ConfigMap overriding the node images published by kind, e.g. with mirrors in air-gapped environments
+	NodeImagesConfigMap types.NamespacedName
+
+	// Container runtime of the KindClusters without Spec.Runtime, detected when empty
+	DefaultRuntime infrastructurev1beta1.ContainerRuntime
 }
 
+// interval between two checks of a long running operation (e.g. a kind cluster creation)
+const operationPollInterval = 10 * time.Second
+
+// interval between two attempts to preload missing or failed images
+const preloadRetryInterval = time.Minute
+
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=kindclusters,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=kindclusters/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=kindclusters/finalizers,verbs=update
@@ -72,7 +92,7 @@ type KindClusterReconciler struct {
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.13.0/pkg/reconcile
-func (r *KindClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, err error) {
+func (r *KindClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
 	logger := log.FromContext(ctx)
 
 	kindCluster := &infrastructurev1beta1.KindCluster{}
@@ -103,85 +123,701 @@ func (r *KindClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		logger.Error(err, "cannot create patch helper")
 		return reconcile.Result{}, err
 	}
+	// Always attempt to patch the KindCluster object and status after each reconciliation.
+	defer func() {
+		if err := r.patchKindCluster(ctx, kindCluster); err != nil {
+			reterr = kerrors.NewAggregate([]error{reterr, err})
+		}
+	}()
+
+	// the kind cluster configuration is built with the node image, resolve it before setting up the kind helper
+	if kindCluster.DeletionTimestamp.IsZero() {
+		overrides, err := nodeImageOverrides(ctx, r.Client, r.NodeImagesConfigMap)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		image, err := kind.ResolveNodeImage(kindCluster, overrides)
+		if err != nil {
+			logger.Info("Cannot resolve node image", "reason", err.Error())
+			conditions.MarkFalse(kindCluster, infrastructurev1beta1.ImageResolvedCondition, infrastructurev1beta1.ImageResolutionFailedReason, clusterv1.ConditionSeverityError, err.Error())
+			r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, "ImageResolutionFailed", "Cannot resolve node image: %v", err)
+			// nothing to do until the spec is fixed
+			return reconcile.Result{}, nil
+		}
+		kindCluster.Status.ResolvedImage = image
+		conditions.MarkTrue(kindCluster, infrastructurev1beta1.ImageResolvedCondition)
+	}
+
+	followFailedPlacement(kindCluster)
+
+	// the docker CLI of the container host is set up for the whole reconciliation, background operations keep it
+	if kindCluster.Spec.HostRef != nil && kindCluster.Status.Host == "" {
+		kindCluster.Status.Host = kindCluster.Spec.HostRef.Name
+	}
+	if kindCluster.Spec.HostSelector != nil && kindCluster.Status.Host == "" &&
+		kindCluster.DeletionTimestamp.IsZero() && !kindClusterStarted(kindCluster) {
+		result, err := r.reconcileHostScheduling(ctx, kindCluster)
+		if err != nil || kindCluster.Status.Host == "" {
+			return result, err
+		}
+	}
+	host, err := kindClusterHost(ctx, r.Client, kindCluster)
+	if err != nil {
+		return r.hostUnavailable(ctx, kindCluster, err)
+	}
+	release, err := kind.AcquireHost(host)
+	if err != nil {
+		return r.hostUnavailable(ctx, kindCluster, err)
+	}
+	defer release()
+
+	// clusters created before their name was recorded are named after the KindCluster, when that kind cluster exists
+	if legacyKindCluster(kindCluster) {
+		legacy := kindCluster.DeepCopy()
+		legacy.Status.KindClusterName = kindCluster.Name
+		exists, err := kind.NewKindLibHelper(logger, legacy, cluster, infrastructurev1beta1.DockerRuntime, host).Exists(ctx, legacy)
+		if err != nil {
+			return r.kindError(ctx, kindCluster, err)
+		}
+		if exists {
+			logger.Info("Adopting kind cluster created before its name was recorded", "cluster", kindCluster.Name)
+			kindCluster.Status.KindClusterName = kindCluster.Name
+			kindCluster.Status.Phase = infrastructurev1beta1.KindClusterPhaseWaitingForControlPlane
+		}
+	}
+
+	// clusters created before the runtime was recorded run on docker
+	if kindCluster.Status.Runtime == "" && kindClusterStarted(kindCluster) {
+		kindCluster.Status.Runtime = infrastructurev1beta1.DockerRuntime
+	}
+	runtime, err := kind.ResolveRuntime(ctx, kindCluster, r.DefaultRuntime, host)
+	if err != nil {
+		logger.Info("Container runtime unavailable", "reason", err.Error())
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.RuntimeAvailableCondition, infrastructurev1beta1.RuntimeUnavailableReason, clusterv1.ConditionSeverityError, err.Error())
+		r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, "RuntimeUnavailable", "Container runtime unavailable: %v", err)
+		// the runtime may be installed or started later
+		return reconcile.Result{RequeueAfter: time.Minute}, nil
+	}
+	kindCluster.Status.Runtime = runtime
+	conditions.MarkTrue(kindCluster, infrastructurev1beta1.RuntimeAvailableCondition)
+
 	// set up kind helper
-	r.kindHelper = kind.NewKindLibHelper(logger, kindCluster, cluster)
+	r.kindHelper = kind.NewKindLibHelper(logger, kindCluster, cluster, runtime, host)
 
 	// Handle deleted clusters
+	var result reconcile.Result
 	if !kindCluster.DeletionTimestamp.IsZero() {
-		return r.reconcileDelete(ctx, kindCluster)
+		result, err = r.reconcileDelete(ctx, cluster, kindCluster)
+	} else {
+		// Handle non-deleted clusters
+		result, err = r.reconcileNormal(ctx, cluster, kindCluster)
 	}
-
-	// Handle non-deleted clusters
-	return r.reconcileNormal(ctx, kindCluster)
+	if err != nil {
+		return r.kindError(ctx, kindCluster, err)
+	}
+	return result, nil
 }
 
-func (r *KindClusterReconciler) reconcileNormal(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster) (reconcile.Result, error) {
+func (r *KindClusterReconciler) reconcileNormal(ctx context.Context, cluster *clusterv1.Cluster, kindCluster *infrastructurev1beta1.KindCluster) (reconcile.Result, error) {
 	logger := log.FromContext(ctx)
 	logger.Info("Reconciling KindCluster")
-	// setting up the finalizer immediatlye
+	// setting up the finalizer immediatlye, with the name of the kind cluster: only the KindClusters of the releases
+	// that did not record it have the finalizer without it, see legacyKindCluster
 	ok := controllerutil.AddFinalizer(kindCluster, infrastructurev1beta1.KindClusterFinalizer)
 	if !ok {
 		logger.Info("Finalizer already exists")
 	}
+	if kindCluster.Status.KindClusterName == "" {
+		kindCluster.Status.KindClusterName = kind.ClusterName(kindCluster)
+	}
 
 	if err := r.patcher.Patch(ctx, kindCluster); err != nil {
 		return reconcile.Result{}, err
 	}
 
-	logger.Info("Search for already existing cluster", "cluster", kindCluster.ObjectMeta.Name)
+	clusterName := kind.ClusterName(kindCluster)
+
+	// Kind cluster creation and scaling run in background, wait for them to complete
+	if op := r.operations.Get(kindCluster.UID); op != nil {
+		if !op.Done() {
+			logger.Info("Kind cluster operation in progress", "cluster", clusterName, "operation", op.action)
+			if op.action == operationUpgrade && kindCluster.Status.Upgrade != nil {
+				kindCluster.Status.Upgrade.UpdatedNodes = op.Progress()
+				conditions.MarkFalse(kindCluster, infrastructurev1beta1.UpToDateCondition, infrastructurev1beta1.UpgradingReason, clusterv1.ConditionSeverityInfo,
+					"upgraded %d of %d nodes to %s", kindCluster.Status.Upgrade.UpdatedNodes, kindCluster.Status.Upgrade.TotalNodes, kindCluster.Status.Upgrade.ToImage)
+			}
+			return reconcile.Result{RequeueAfter: operationPollInterval}, nil
+		}
+		r.operations.Forget(kindCluster.UID)
+		if op.action == operationPreload {
+			if !r.completePreload(ctx, kindCluster, op) {
+				// retried later, not right away
+				return reconcile.Result{RequeueAfter: preloadRetryInterval}, nil
+			}
+		} else if op.action == operationRecreate || op.action == operationUpgrade {
+			r.completeUpgrade(ctx, kindCluster, op)
+		} else if op.action == operationRepair || op.action == operationRebuild {
+			if !r.completeRemediation(ctx, kindCluster, op) {
+				return reconcile.Result{RequeueAfter: driftCheckInterval}, nil
+			}
+		} else if op.action != operationCreate {
+			r.completeScaling(ctx, kindCluster, op)
+		} else if err := op.Err(); err != nil {
+			return r.createFailed(ctx, kindCluster, err), nil
+		} else {
+			logger.Info("Kind cluster created", "cluster", clusterName)
+			r.Recorder.Eventf(kindCluster, corev1.EventTypeNormal, "Created", "Kind cluster %s created", clusterName)
+			kindCluster.Status.Phase = infrastructurev1beta1.KindClusterPhaseWaitingForControlPlane
+			kindCluster.Status.CreateAttempts = 0
+			kindCluster.Status.LastCreateFailureTime = nil
+		}
+	}
+
+	// failed creations are attempted again after a backoff, up to Spec.MaxCreateAttempts times
+	if result, done := r.reconcileCreateFailure(ctx, kindCluster); done {
+		return result, nil
+	}
+
+	logger.Info("Search for already existing cluster", "cluster", clusterName)
 	ok, err := r.kindHelper.Exists(ctx, kindCluster)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
 
-	if !ok {
-		logger.Info("Creating cluster", "cluster", kindCluster.ObjectMeta.Name)
-		err := r.kindHelper.Create(ctx, kindCluster)
+	// A kind cluster we did not create yet may belong to someone else: never adopt it unless asked to
+	if kindCluster.Spec.Import {
+		if result, err := r.reconcileImport(ctx, kindCluster, ok); err != nil || !result.IsZero() {
+			return result, err
+		}
+	} else if ok && !kindClusterStarted(kindCluster) {
+		owned, err := r.kindHelper.IsOwned(ctx, kindCluster)
 		if err != nil {
 			return reconcile.Result{}, err
 		}
+		if !owned {
+			logger.Info("Kind cluster already exists and is not owned by this KindCluster", "cluster", clusterName)
+			conditions.MarkFalse(kindCluster, infrastructurev1beta1.KindClusterCreatedCondition, infrastructurev1beta1.KindClusterNameConflictReason, clusterv1.ConditionSeverityError,
+				"kind cluster %s already exists and is not owned by this KindCluster", clusterName)
+			r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, "NameConflict", "Kind cluster %s already exists and is not owned by this KindCluster", clusterName)
+			kindCluster.Status.Ready = false
+			return reconcile.Result{RequeueAfter: time.Minute}, nil
+		}
+	}
+	kindCluster.Status.KindClusterName = clusterName
+
+	// Still provisioning but no creation running: the controller has been restarted in the middle of it.
+	// A failed creation whose containers could not be removed is cleaned up before the next attempt.
+	if ok && (kindCluster.Status.Phase == infrastructurev1beta1.KindClusterPhaseProvisioning || kindCluster.Status.Phase == infrastructurev1beta1.KindClusterPhaseFailed) {
+		logger.Info("Deleting partially created cluster", "cluster", clusterName)
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.KindClusterCreatedCondition, infrastructurev1beta1.KindClusterPartiallyCreatedReason, clusterv1.ConditionSeverityWarning,
+			"kind cluster %s creation has been interrupted", clusterName)
+		r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, "PartiallyCreated", "Kind cluster %s creation has been interrupted, deleting it", clusterName)
+		if err := r.kindHelper.Delete(ctx, kindCluster); err != nil {
+			return reconcile.Result{}, err
+		}
+		ok = false
 	}
 
+	if !ok {
+		logger.Info("Creating cluster", "cluster", clusterName)
+		kindCluster.Status.Phase = infrastructurev1beta1.KindClusterPhaseProvisioning
+		kindCluster.Status.Ready = false
+		kindCluster.Status.Image = kind.NodeImage(kindCluster)
+		kindCluster.Status.ControlPlaneImage = ""
+		kindCluster.Status.ControlPlaneNodes = kind.ControlPlaneCount(kindCluster)
+		kindCluster.Status.WorkerNodes = kindCluster.Spec.WorkerCount
+		kindCluster.Status.Upgrade = nil
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.KindClusterCreatedCondition, infrastructurev1beta1.KindClusterProvisioningReason, clusterv1.ConditionSeverityInfo,
+			"creating kind cluster %s", clusterName)
+
+		helper, toCreate := r.kindHelper, kindCluster.DeepCopy()
+		r.operations.Start(kindCluster.UID, operationCreate, onHost(ctx, helper, func() error {
+			return helper.Create(ctx, toCreate)
+		}))
+		return reconcile.Result{RequeueAfter: operationPollInterval}, nil
+	}
+	conditions.MarkTrue(kindCluster, infrastructurev1beta1.KindClusterCreatedCondition)
+
+	// node containers may have been stopped or removed behind our back, imported kind clusters are not compared
+	// with the spec
+	if !kindCluster.Spec.Import {
+		if result, err := r.reconcileDrift(ctx, kindCluster); err != nil || !result.IsZero() {
+			return result, err
+		}
+	}
+
+	ready, err := r.kindHelper.ControlPlaneReady(ctx, kindCluster)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if !ready {
+		logger.Info("Waiting for control plane to be ready", "cluster", clusterName)
+		kindCluster.Status.Phase = infrastructurev1beta1.KindClusterPhaseWaitingForControlPlane
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.ControlPlaneReadyCondition, infrastructurev1beta1.WaitingForControlPlaneReason, clusterv1.ConditionSeverityInfo, "")
+		return reconcile.Result{RequeueAfter: operationPollInterval}, nil
+	}
+	conditions.MarkTrue(kindCluster, infrastructurev1beta1.ControlPlaneReadyCondition)
+
+	kubeVersion, err := r.kindHelper.KubeVersion(ctx, kindCluster)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	kindCluster.Status.Version = kubeVersion
+	kindCluster.Status.AlphaFeatures = strings.Join(featuregates.AlphaFeatures(kubeVersion, kindCluster.Spec.FeatureGates, kindCluster.Spec.RuntimeConfig), ",")
+
+	// ErrEndpointUnavailable, reported in the EndpointResolved condition, see kindError
 	host, port, err := r.kindHelper.Endpoint(ctx, kindCluster)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
+	conditions.MarkTrue(kindCluster, infrastructurev1beta1.EndpointResolvedCondition)
 
 	logger.Info("Setting controlPlaneEndpoint", "host", host, "port", port)
 
 	kindCluster.Spec.ControlPlaneEndpoint.Host = host
 	kindCluster.Spec.ControlPlaneEndpoint.Port = int32(port)
+
+	if err := r.reconcileKubeconfig(ctx, cluster, kindCluster); err != nil {
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.KubeconfigPublishedCondition, infrastructurev1beta1.KubeconfigPublishFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return reconcile.Result{}, err
+	}
+	conditions.MarkTrue(kindCluster, infrastructurev1beta1.KubeconfigPublishedCondition)
+
+	if kindCluster.Spec.Registry != nil {
+		if err := r.kindHelper.ReconcileRegistry(ctx, kindCluster); err != nil {
+			conditions.MarkFalse(kindCluster, infrastructurev1beta1.RegistryReadyCondition, infrastructurev1beta1.RegistryFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			return reconcile.Result{}, err
+		}
+		conditions.MarkTrue(kindCluster, infrastructurev1beta1.RegistryReadyCondition)
+	}
+
+	healthy, err := r.reconcileHealth(ctx, kindCluster)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if !healthy {
+		kindCluster.Status.Ready = false
+		if kindCluster.Status.Phase == infrastructurev1beta1.KindClusterPhaseReady {
+			r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, "Unhealthy", "Kind cluster %s health checks failing", clusterName)
+			kindCluster.Status.Phase = infrastructurev1beta1.KindClusterPhaseDegraded
+		}
+		return reconcile.Result{RequeueAfter: operationPollInterval}, nil
+	}
+
 	kindCluster.Status.Ready = true
-	kindCluster.Status.Nodes = kindCluster.Spec.WorkerCount + kindCluster.Spec.ControlPlaneCount
-	r.patcher.Patch(ctx, kindCluster)
+	kindCluster.Status.Phase = infrastructurev1beta1.KindClusterPhaseReady
+	kindCluster.Status.FailureReason = nil
+	kindCluster.Status.FailureMessage = nil
+	kindCluster.Status.FailureGeneration = 0
+
+	// imported kind clusters are kept as they are
+	if !kindCluster.Spec.Import {
+		upgrading, err := r.reconcileUpgrade(ctx, kindCluster)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if upgrading {
+			return reconcile.Result{RequeueAfter: operationPollInterval}, nil
+		}
 
-	return reconcile.Result{}, nil
+		result, err := r.reconcileWorkers(ctx, kindCluster)
+		if err != nil || !result.IsZero() {
+			return result, err
+		}
+	}
+	result, err := r.reconcilePreload(ctx, kindCluster)
+	if err != nil || !result.IsZero() {
+		return result, err
+	}
+	return reconcile.Result{RequeueAfter: driftCheckInterval}, nil
 }
 
-func (r *KindClusterReconciler) reconcileDelete(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster) (reconcile.Result, error) {
+// reconcileUpgrade moves the kind cluster to the node image of its spec when it changed, in background,
+// with the strategy of the spec. It reports whether an upgrade has been started.
+func (r *KindClusterReconciler) reconcileUpgrade(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster) (bool, error) {
 	logger := log.FromContext(ctx)
+	clusterName := kind.ClusterName(kindCluster)
 
-	logger.Info("Deleting KindCluster")
+	image := kind.NodeImage(kindCluster)
+	if kindCluster.Status.Image == "" {
+		// created before the running image was recorded
+		kindCluster.Status.Image = image
+	}
+	if nodeimage.SameImage(image, kindCluster.Status.Image) {
+		kindCluster.Status.Upgrade = nil
+		conditions.MarkTrue(kindCluster, infrastructurev1beta1.UpToDateCondition)
+		return false, nil
+	}
 
-	// delete logic
+	targetVersion, err := r.imageKubeVersion(ctx, kindCluster, image)
+	if err != nil {
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.UpToDateCondition, infrastructurev1beta1.UpgradeFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return false, err
+	}
+	if isDowngrade(kindCluster.Status.Version, targetVersion) && !kindCluster.Spec.AllowDowngrade {
+		logger.Info("Refusing to downgrade kind cluster", "cluster", clusterName, "from", kindCluster.Status.Version, "to", targetVersion)
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.UpToDateCondition, infrastructurev1beta1.DowngradeNotAllowedReason, clusterv1.ConditionSeverityError,
+			"refusing to downgrade from %s to %s, set spec.allowDowngrade to allow it", kindCluster.Status.Version, targetVersion)
+		r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, "DowngradeNotAllowed", "Refusing to downgrade kind cluster %s from %s to %s", clusterName, kindCluster.Status.Version, targetVersion)
+		return false, nil
+	}
 
-	if ok, err := r.kindHelper.Exists(ctx, kindCluster); ok == false || err != nil {
-		logger.Info("Cluster does not exist, skip", "cluster", kindCluster.ObjectMeta.Name)
+	strategy := kindCluster.Spec.UpgradeStrategy
+	if strategy == "" {
+		strategy = infrastructurev1beta1.RollingUpdateUpgradeStrategyType
+	}
+	logger.Info("Upgrading kind cluster", "cluster", clusterName, "from", kindCluster.Status.Image, "to", image, "strategy", strategy)
+	kindCluster.Status.Upgrade = &infrastructurev1beta1.KindClusterUpgradeStatus{
+		FromImage:  kindCluster.Status.Image,
+		ToImage:    image,
+		Strategy:   strategy,
+		TotalNodes: kindCluster.Status.Nodes,
+	}
+	kindCluster.Status.Phase = infrastructurev1beta1.KindClusterPhaseUpgrading
+	conditions.MarkFalse(kindCluster, infrastructurev1beta1.UpToDateCondition, infrastructurev1beta1.UpgradingReason, clusterv1.ConditionSeverityInfo,
+		"upgrading from %s to %s", kindCluster.Status.Image, image)
+	r.Recorder.Eventf(kindCluster, corev1.EventTypeNormal, "Upgrading", "Upgrading kind cluster %s to %s (%s)", clusterName, image, strategy)
+
+	helper, toUpgrade, uid := r.kindHelper, kindCluster.DeepCopy(), kindCluster.UID
+	if strategy == infrastructurev1beta1.RecreateUpgradeStrategyType {
+		// the kubeconfig Secret is kept and updated once the new cluster is up
+		kindCluster.Status.Ready = false
+		r.operations.Start(uid, operationRecreate, onHost(ctx, helper, func() error {
+			if err := helper.Delete(ctx, toUpgrade); err != nil {
+				return err
+			}
+			return helper.Create(ctx, toUpgrade)
+		}))
+	} else {
+		r.operations.Start(uid, operationUpgrade, onHost(ctx, helper, func() error {
+			return helper.Upgrade(ctx, toUpgrade, image, func(updated int32) {
+				r.operations.SetProgress(uid, updated)
+			})
+		}))
 	}
+	return true, nil
+}
 
-	err := r.kindHelper.Delete(ctx, kindCluster)
+// completeUpgrade reports the result of a completed upgrade operation
+func (r *KindClusterReconciler) completeUpgrade(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster, op *operation) {
+	logger := log.FromContext(ctx)
+	upgrade := kindCluster.Status.Upgrade
+	if upgrade == nil {
+		return
+	}
+
+	if err := op.Err(); err != nil {
+		logger.Error(err, "Failed to upgrade kind cluster", "operation", op.action)
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.UpToDateCondition, infrastructurev1beta1.UpgradeFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, "UpgradeFailed", "Failed to upgrade kind cluster to %s: %v", upgrade.ToImage, err)
+		return
+	}
+
+	r.Recorder.Eventf(kindCluster, corev1.EventTypeNormal, "Upgraded", "Kind cluster upgraded to %s", upgrade.ToImage)
+	switch {
+	case op.action == operationRecreate:
+		kindCluster.Status.ControlPlaneImage = ""
+	case kindCluster.Status.ControlPlaneImage == "":
+		// the containers of the control plane nodes keep running the image they were created from
+		kindCluster.Status.ControlPlaneImage = upgrade.FromImage
+	}
+	kindCluster.Status.Image = upgrade.ToImage
+	if nodeimage.SameImage(kindCluster.Status.ControlPlaneImage, kindCluster.Status.Image) {
+		kindCluster.Status.ControlPlaneImage = ""
+	}
+	kindCluster.Status.Upgrade = nil
+	// the nodes have been replaced, load the images again
+	kindCluster.Status.PreloadedImages = nil
+	conditions.MarkTrue(kindCluster, infrastructurev1beta1.UpToDateCondition)
+	if op.action == operationRecreate {
+		kindCluster.Status.Phase = infrastructurev1beta1.KindClusterPhaseWaitingForControlPlane
+	}
+}
+
+// imageKubeVersion returns the Kubernetes version shipped in the node image, read from the image only once per image:
+// reading it runs a container
+func (r *KindClusterReconciler) imageKubeVersion(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster, image string) (string, error) {
+	if target := kindCluster.Status.TargetImage; target != nil && target.Image == image {
+		return target.Version, nil
+	}
+	version, err := r.kindHelper.ImageKubeVersion(ctx, image)
+	if err != nil {
+		return "", err
+	}
+	kindCluster.Status.TargetImage = &infrastructurev1beta1.NodeImageVersion{Image: image, Version: version}
+	return version, nil
+}
+
+// isDowngrade reports whether moving from version current to version target is a downgrade,
+// unknown versions are never a downgrade
+func isDowngrade(current, target string) bool {
+	currentVersion, err := version.ParseGeneric(current)
+	if err != nil {
+		return false
+	}
+	targetVersion, err := version.ParseGeneric(target)
+	if err != nil {
+		return false
+	}
+	return targetVersion.LessThan(currentVersion)
+}
+
+// reconcileWorkers adds or removes one worker node at a time until the kind cluster matches Spec.WorkerCount.
+// Nodes are added and removed in background, like the cluster creation.
+func (r *KindClusterReconciler) reconcileWorkers(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster) (reconcile.Result, error) {
+	logger := log.FromContext(ctx)
+	clusterName := kind.ClusterName(kindCluster)
+
+	workers, err := r.kindHelper.Workers(ctx, kindCluster)
 	if err != nil {
 		return reconcile.Result{}, err
 	}
+	current, desired := int32(len(workers)), kindCluster.Spec.WorkerCount
+
+	helper, toScale := r.kindHelper, kindCluster.DeepCopy()
+	switch {
+	case current < desired:
+		logger.Info("Adding worker node", "cluster", clusterName, "workers", current, "desired", desired)
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.WorkersReadyCondition, infrastructurev1beta1.ScalingUpReason, clusterv1.ConditionSeverityInfo,
+			"scaling workers from %d to %d", current, desired)
+		r.operations.Start(kindCluster.UID, operationScaleUp, onHost(ctx, helper, func() error {
+			_, err := helper.AddWorker(ctx, toScale)
+			return err
+		}))
+	case current > desired:
+		// always remove the most recent worker, kind names them by index
+		name := workers[len(workers)-1]
+		logger.Info("Removing worker node", "cluster", clusterName, "node", name, "workers", current, "desired", desired)
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.WorkersReadyCondition, infrastructurev1beta1.ScalingDownReason, clusterv1.ConditionSeverityInfo,
+			"scaling workers from %d to %d", current, desired)
+		r.operations.Start(kindCluster.UID, operationScaleDown, onHost(ctx, helper, func() error {
+			return helper.RemoveWorker(ctx, toScale, name)
+		}))
+	default:
+		kindCluster.Status.WorkerNodes = current
+		conditions.MarkTrue(kindCluster, infrastructurev1beta1.WorkersReadyCondition)
+		return reconcile.Result{}, nil
+	}
 
-	controllerutil.RemoveFinalizer(kindCluster, infrastructurev1beta1.KindClusterFinalizer)
-	if err := r.patcher.Patch(ctx, kindCluster); err != nil {
+	return reconcile.Result{RequeueAfter: operationPollInterval}, nil
+}
+
+// completeScaling reports the result of a completed scaling operation
+func (r *KindClusterReconciler) completeScaling(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster, op *operation) {
+	logger := log.FromContext(ctx)
+
+	if err := op.Err(); err != nil {
+		logger.Error(err, "Failed to scale workers", "operation", op.action)
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.WorkersReadyCondition, infrastructurev1beta1.ScalingFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, "ScalingFailed", "Failed to scale workers (%s): %v", op.action, err)
+		return
+	}
+	// status.workerNodes follows the workers, a removed worker must not be reported missing
+	if op.action == operationScaleUp {
+		// the new node has none of the preloaded images
+		kindCluster.Status.PreloadedImages = nil
+		kindCluster.Status.WorkerNodes++
+		r.Recorder.Eventf(kindCluster, corev1.EventTypeNormal, "ScaledUp", "Worker node added")
+	} else {
+		if kindCluster.Status.WorkerNodes > 0 {
+			kindCluster.Status.WorkerNodes--
+		}
+		r.Recorder.Eventf(kindCluster, corev1.EventTypeNormal, "ScaledDown", "Worker node removed")
+	}
+}
+
+// reconcilePreload loads the images of Spec.PreloadImages into the nodes in background, when some of them are not
+// recorded as loaded into all the nodes
+func (r *KindClusterReconciler) reconcilePreload(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster) (reconcile.Result, error) {
+	logger := log.FromContext(ctx)
+
+	if imagesPreloaded(kindCluster) {
+		return reconcile.Result{}, nil
+	}
+	if len(kindCluster.Spec.PreloadImages) == 0 {
+		kindCluster.Status.PreloadedImages = nil
+		return reconcile.Result{}, nil
+	}
+
+	logger.Info("Preloading images", "cluster", kind.ClusterName(kindCluster), "images", kindCluster.Spec.PreloadImages)
+	helper, toPreload, uid := r.kindHelper, kindCluster.DeepCopy(), kindCluster.UID
+	r.operations.Start(uid, operationPreload, onHost(ctx, helper, func() error {
+		images, err := helper.PreloadImages(ctx, toPreload)
+		r.operations.SetPreloaded(uid, images)
+		return err
+	}))
+	return reconcile.Result{RequeueAfter: operationPollInterval}, nil
+}
+
+// completePreload records the state of the images of a completed preload operation, it reports whether
+// all of them have been loaded
+func (r *KindClusterReconciler) completePreload(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster, op *operation) bool {
+	logger := log.FromContext(ctx)
+
+	if err := op.Err(); err != nil {
+		logger.Error(err, "Failed to preload images")
+		r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, "PreloadFailed", "Failed to preload images: %v", err)
+		return false
+	}
+	kindCluster.Status.PreloadedImages = op.preloaded
+	for _, image := range op.preloaded {
+		if image.State != infrastructurev1beta1.PreloadedImageLoaded {
+			r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, "PreloadFailed", "Image %s not preloaded (%s): %s", image.Image, image.State, image.Message)
+		}
+	}
+	return imagesPreloaded(kindCluster)
+}
+
+// imagesPreloaded reports whether the images of Spec.PreloadImages are all recorded as loaded into all the nodes
+func imagesPreloaded(kindCluster *infrastructurev1beta1.KindCluster) bool {
+	if len(kindCluster.Spec.PreloadImages) != len(kindCluster.Status.PreloadedImages) {
+		return false
+	}
+	for i, image := range kindCluster.Spec.PreloadImages {
+		status := kindCluster.Status.PreloadedImages[i]
+		if status.Image != image || status.State != infrastructurev1beta1.PreloadedImageLoaded {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *KindClusterReconciler) reconcileDelete(ctx context.Context, cluster *clusterv1.Cluster, kindCluster *infrastructurev1beta1.KindCluster) (reconcile.Result, error) {
+	logger := log.FromContext(ctx)
+
+	logger.Info("Deleting KindCluster")
+	clusterName := kind.ClusterName(kindCluster)
+
+	// a kind cluster creation or scaling cannot be interrupted, wait for it to complete
+	if op := r.operations.Get(kindCluster.UID); op != nil {
+		if !op.Done() {
+			logger.Info("Waiting for kind cluster operation to complete before deleting it", "cluster", clusterName, "operation", op.action)
+			return reconcile.Result{RequeueAfter: operationPollInterval}, nil
+		}
+		r.operations.Forget(kindCluster.UID)
+	}
+
+	// delete logic
+
+	// the finalizer is kept while the runtime is unavailable, the kind cluster may still exist
+	owned := true
+	if ok, err := r.kindHelper.Exists(ctx, kindCluster); err != nil {
 		return reconcile.Result{}, err
+	} else if !ok {
+		logger.Info("Cluster does not exist, skip", "cluster", clusterName)
+	} else if !kindClusterStarted(kindCluster) {
+		if owned, err = r.kindHelper.IsOwned(ctx, kindCluster); err != nil {
+			return reconcile.Result{}, err
+		}
 	}
 
+	conditions.MarkFalse(kindCluster, infrastructurev1beta1.KindClusterCreatedCondition, infrastructurev1beta1.KindClusterDeletingReason, clusterv1.ConditionSeverityInfo, "")
+	kindCluster.Status.Ready = false
+
+	if owned && kindCluster.Spec.DeletionPolicy == infrastructurev1beta1.OrphanDeletionPolicy {
+		logger.Info("Leaving kind cluster running, deletion policy is Orphan", "cluster", clusterName)
+		r.Recorder.Eventf(kindCluster, corev1.EventTypeNormal, "Orphaned", "Kind cluster %s left running, deletion policy is Orphan", clusterName)
+	} else if owned {
+		kindCluster.Status.Phase = infrastructurev1beta1.KindClusterPhaseDeleting
+		err := r.kindHelper.Delete(ctx, kindCluster)
+		if err != nil {
+			conditions.MarkFalse(kindCluster, infrastructurev1beta1.KindClusterCreatedCondition, infrastructurev1beta1.KindClusterDeleteFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, "DeleteFailed", "Failed to delete kind cluster: %v", err)
+			return reconcile.Result{}, err
+		}
+		if kindCluster.Spec.Registry != nil {
+			if err := r.kindHelper.DeleteRegistry(ctx, kindCluster); err != nil {
+				r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, "DeleteFailed", "Failed to delete local registry: %v", err)
+				return reconcile.Result{}, err
+			}
+		}
+	} else {
+		logger.Info("Kind cluster is not owned by this KindCluster, leaving it untouched", "cluster", clusterName)
+		r.Recorder.Eventf(kindCluster, corev1.EventTypeNormal, "DeleteSkipped", "Kind cluster %s is not owned by this KindCluster, leaving it untouched", clusterName)
+	}
+
+	if err := r.deleteKubeconfig(ctx, cluster); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	controllerutil.RemoveFinalizer(kindCluster, infrastructurev1beta1.KindClusterFinalizer)
+
 	return reconcile.Result{}, nil
 }
 
+// hostUnavailable reports a container host that cannot be used, e.g. a missing KindHost
+func (r *KindClusterReconciler) hostUnavailable(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster, err error) (reconcile.Result, error) {
+	log.FromContext(ctx).Info("Container host unavailable", "reason", err.Error())
+	conditions.MarkFalse(kindCluster, infrastructurev1beta1.RuntimeAvailableCondition, infrastructurev1beta1.HostUnavailableReason, clusterv1.ConditionSeverityError, err.Error())
+	r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, "HostUnavailable", "Container host unavailable: %v", err)
+	// the KindHost or its Secret may be created later
+	return reconcile.Result{RequeueAfter: time.Minute}, nil
+}
+
+// kindClusterStarted reports whether the creation of the kind cluster has been started for this KindCluster,
+// i.e. an existing kind cluster with its name is known to be ours
+func kindClusterStarted(kindCluster *infrastructurev1beta1.KindCluster) bool {
+	return kindCluster.Status.Phase != "" || conditions.IsTrue(kindCluster, infrastructurev1beta1.KindClusterCreatedCondition)
+}
+
+// legacyKindCluster reports whether the KindCluster has been reconciled by a release recording neither the name of
+// its kind cluster nor its phase: it has the finalizer and nothing else
+func legacyKindCluster(kindCluster *infrastructurev1beta1.KindCluster) bool {
+	return kindCluster.Status.KindClusterName == "" && kindCluster.Spec.KindClusterName == "" && !kindCluster.Spec.Import &&
+		kindCluster.Status.Phase == "" && !conditions.Has(kindCluster, infrastructurev1beta1.KindClusterCreatedCondition) &&
+		controllerutil.ContainsFinalizer(kindCluster, infrastructurev1beta1.KindClusterFinalizer)
+}
+
+// patchKindCluster summarizes the KindCluster conditions in the Ready condition and patches the object.
+func (r *KindClusterReconciler) patchKindCluster(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster) error {
+	summary := []clusterv1.ConditionType{
+		infrastructurev1beta1.RuntimeAvailableCondition,
+		infrastructurev1beta1.ImageResolvedCondition,
+		infrastructurev1beta1.KindClusterCreatedCondition,
+		infrastructurev1beta1.ControlPlaneReadyCondition,
+		infrastructurev1beta1.EndpointResolvedCondition,
+		infrastructurev1beta1.KubeconfigPublishedCondition,
+		infrastructurev1beta1.APIServerHealthyCondition,
+		infrastructurev1beta1.NodesReadyCondition,
+		infrastructurev1beta1.SystemPodsReadyCondition,
+	}
+	// the step counter counts the node containers and the workers, the registry and the scheduling only when
+	// they are managed
+	if !kindCluster.Spec.Import {
+		summary = append(summary, infrastructurev1beta1.NodesHealthyCondition, infrastructurev1beta1.WorkersReadyCondition)
+	}
+	if kindCluster.Spec.Registry != nil {
+		summary = append(summary, infrastructurev1beta1.RegistryReadyCondition)
+	}
+	if kindCluster.Spec.HostSelector != nil {
+		summary = append(summary, infrastructurev1beta1.HostScheduledCondition)
+	}
+	conditions.SetSummary(kindCluster,
+		conditions.WithConditions(summary...),
+		conditions.WithStepCounterIf(kindCluster.ObjectMeta.DeletionTimestamp.IsZero()),
+	)
+
+	return r.patcher.Patch(ctx, kindCluster,
+		patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{
+			clusterv1.ReadyCondition,
+			infrastructurev1beta1.RuntimeAvailableCondition,
+			infrastructurev1beta1.ImageResolvedCondition,
+			infrastructurev1beta1.KindClusterCreatedCondition,
+			infrastructurev1beta1.NodesHealthyCondition,
+			infrastructurev1beta1.ControlPlaneReadyCondition,
+			infrastructurev1beta1.EndpointResolvedCondition,
+			infrastructurev1beta1.KubeconfigPublishedCondition,
+			infrastructurev1beta1.APIServerHealthyCondition,
+			infrastructurev1beta1.NodesReadyCondition,
+			infrastructurev1beta1.SystemPodsReadyCondition,
+			infrastructurev1beta1.WorkersReadyCondition,
+			infrastructurev1beta1.UpToDateCondition,
+			infrastructurev1beta1.RegistryReadyCondition,
+			infrastructurev1beta1.HostScheduledCondition,
+		}},
+	)
+}
+
 // Filter out change of status subresource
 func filterStatusChanges() predicate.Predicate {
 	return predicate.Funcs{
@@ -197,11 +833,18 @@ func filterStatusChanges() predicate.Predicate {
 func (r *KindClusterReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
 	log := ctrl.LoggerFrom(ctx)
 
+	if r.operations == nil {
+		r.operations = newOperationTracker()
+	}
+	if r.scheduled == nil {
+		r.scheduled = newScheduledHosts()
+	}
+
 	controller, err := ctrl.NewControllerManagedBy(mgr).
 		For(&infrastructurev1beta1.KindCluster{}).
 		WithEventFilter(predicates.ResourceNotPaused(log)).
 		WithEventFilter(predicates.ResourceIsNotExternallyManaged(log)).
-		WithEventFilter(filterStatusChanges()).
+		WithEventFilter(predicate.Or(filterStatusChanges(), retryCreateAnnotated())).
 		Build(r)
 
 	if err != nil {
@@ -0,0 +1,69 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	infrastructurev1beta1 "github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLegacyKindCluster(t *testing.T) {
+	finalizer := []string{infrastructurev1beta1.KindClusterFinalizer}
+	tests := []struct {
+		name        string
+		kindCluster infrastructurev1beta1.KindCluster
+		want        bool
+	}{
+		{
+			name:        "reconciled by a release not recording the name",
+			kindCluster: infrastructurev1beta1.KindCluster{ObjectMeta: metav1.ObjectMeta{Finalizers: finalizer}},
+			want:        true,
+		},
+		{
+			// the name is recorded with the finalizer, a failure afterwards leaves both
+			name: "reconciled once",
+			kindCluster: infrastructurev1beta1.KindCluster{
+				ObjectMeta: metav1.ObjectMeta{Finalizers: finalizer},
+				Status:     infrastructurev1beta1.KindClusterStatus{KindClusterName: "default-dev-0123abcd"},
+			},
+		},
+		{
+			name: "new",
+		},
+		{
+			name: "kind cluster name in the spec",
+			kindCluster: infrastructurev1beta1.KindCluster{
+				ObjectMeta: metav1.ObjectMeta{Finalizers: finalizer},
+				Spec:       infrastructurev1beta1.KindClusterSpec{KindClusterName: "dev"},
+			},
+		},
+		{
+			name: "creation started",
+			kindCluster: infrastructurev1beta1.KindCluster{
+				ObjectMeta: metav1.ObjectMeta{Finalizers: finalizer},
+				Status:     infrastructurev1beta1.KindClusterStatus{Phase: infrastructurev1beta1.KindClusterPhaseProvisioning},
+			},
+		},
+	}
+	for _, tt := range tests {
+		g := NewWithT(t)
+		g.Expect(legacyKindCluster(&tt.kindCluster)).To(Equal(tt.want), tt.name)
+	}
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	infrastructurev1beta1 "github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/mbovo/cluster-api-provider-kind/pkg/kind"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	// delay before the second attempt to create a kind cluster, doubled after each failed attempt
+	createBackoffBase = 10 * time.Second
+	// longest delay between two attempts to create a kind cluster
+	createBackoffMax = 5 * time.Minute
+	// attempts to create a kind cluster of KindClusters created before Spec.MaxCreateAttempts existed
+	defaultMaxCreateAttempts = 5
+)
+
+// createBackoff returns the delay before the next attempt to create a kind cluster after the given number of
+// failed attempts
+func createBackoff(attempts int32) time.Duration {
+	backoff := createBackoffBase
+	for i := int32(1); i < attempts; i++ {
+		backoff *= 2
+		if backoff >= createBackoffMax {
+			return createBackoffMax
+		}
+	}
+	return backoff
+}
+
+// maxCreateAttempts returns the number of attempts to create the kind cluster before giving up
+func maxCreateAttempts(kindCluster *infrastructurev1beta1.KindCluster) int32 {
+	if kindCluster.Spec.MaxCreateAttempts <= 0 {
+		return defaultMaxCreateAttempts
+	}
+	return kindCluster.Spec.MaxCreateAttempts
+}
+
+// createFailed records a failed attempt to create the kind cluster, the containers left behind have already been
+// removed. The creation is attempted again after a backoff, until Spec.MaxCreateAttempts attempts failed: the
+// failure is terminal then, until the spec changes or the RetryCreateAnnotation is set.
+func (r *KindClusterReconciler) createFailed(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster, err error) reconcile.Result {
+	logger := log.FromContext(ctx)
+
+	// an unavailable runtime does not count as an attempt, an invalid configuration is not worth another one
+	if !kind.IsTransient(err) {
+		kindCluster.Status.CreateAttempts++
+	}
+	kindCluster.Status.LastCreateFailureTime = &metav1.Time{Time: time.Now()}
+	kindCluster.Status.Phase = infrastructurev1beta1.KindClusterPhaseFailed
+	kindCluster.Status.Ready = false
+
+	attempts, max := kindCluster.Status.CreateAttempts, maxCreateAttempts(kindCluster)
+	logger.Error(err, "Failed to create kind cluster", "attempt", attempts, "maxAttempts", max)
+	if attempts < max && !kind.IsTerminal(err) {
+		backoff := createBackoff(attempts)
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.KindClusterCreatedCondition, infrastructurev1beta1.KindClusterCreateFailedReason, clusterv1.ConditionSeverityWarning,
+			"attempt %d of %d failed, retrying in %s: %v", attempts, max, backoff, err)
+		r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, "CreateFailed", "Failed to create kind cluster (attempt %d of %d), retrying in %s: %v", attempts, max, backoff, err)
+		return reconcile.Result{RequeueAfter: backoff}
+	}
+
+	conditions.MarkFalse(kindCluster, infrastructurev1beta1.KindClusterCreatedCondition, infrastructurev1beta1.KindClusterCreateFailedReason, clusterv1.ConditionSeverityError,
+		"giving up after %d attempts, change the spec or set the %s annotation to retry: %v", attempts, infrastructurev1beta1.RetryCreateAnnotation, err)
+	failureReason := capierrors.CreateClusterError
+	if errors.Is(err, kind.ErrInvalidConfig) {
+		failureReason = capierrors.InvalidConfigurationClusterError
+	}
+	kindCluster.Status.FailureReason = &failureReason
+	kindCluster.Status.FailureGeneration = kindCluster.Generation
+	kindCluster.Status.FailureMessage = pointer.String(fmt.Sprintf("failed to create kind cluster after %d attempts: %v", attempts, err))
+	r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, "CreateFailed", "Failed to create kind cluster, giving up after %d attempts: %v", attempts, err)
+	return reconcile.Result{}
+}
+
+// reconcileCreateFailure holds the next attempt to create a kind cluster whose creation failed until its backoff
+// expired, until the spec changes once the failure is terminal. A spec newer than the failure, or the
+// RetryCreateAnnotation, which is removed, resets the attempts.
+// done is set, with the result to return, while the creation must not be attempted.
+func (r *KindClusterReconciler) reconcileCreateFailure(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster) (result reconcile.Result, done bool) {
+	logger := log.FromContext(ctx)
+
+	if kindCluster.Status.Phase != infrastructurev1beta1.KindClusterPhaseFailed {
+		return reconcile.Result{}, false
+	}
+
+	_, retry := kindCluster.Annotations[infrastructurev1beta1.RetryCreateAnnotation]
+	specChanged := kindCluster.Status.FailureReason != nil && kindCluster.Generation > kindCluster.Status.FailureGeneration
+	if retry || specChanged {
+		logger.Info("Retrying kind cluster creation", "attempts", kindCluster.Status.CreateAttempts, "specChanged", specChanged)
+		r.Recorder.Eventf(kindCluster, corev1.EventTypeNormal, "RetryCreate", "Retrying kind cluster creation after %d failed attempts", kindCluster.Status.CreateAttempts)
+		delete(kindCluster.Annotations, infrastructurev1beta1.RetryCreateAnnotation)
+		kindCluster.Status.CreateAttempts = 0
+		kindCluster.Status.LastCreateFailureTime = nil
+		kindCluster.Status.FailureReason = nil
+		kindCluster.Status.FailureMessage = nil
+		kindCluster.Status.FailureGeneration = 0
+		return reconcile.Result{}, false
+	}
+
+	if kindCluster.Status.FailureReason != nil {
+		logger.Info("Kind cluster creation failed, waiting for a spec change or the retry annotation", "annotation", infrastructurev1beta1.RetryCreateAnnotation)
+		return reconcile.Result{}, true
+	}
+
+	if last := kindCluster.Status.LastCreateFailureTime; last != nil {
+		if wait := time.Until(last.Add(createBackoff(kindCluster.Status.CreateAttempts))); wait > 0 {
+			logger.Info("Waiting before retrying kind cluster creation", "attempts", kindCluster.Status.CreateAttempts, "backoff", wait)
+			return reconcile.Result{RequeueAfter: wait}, true
+		}
+	}
+	return reconcile.Result{}, false
+}
+
+// followFailedPlacement forgets the container host and the runtime recorded for a kind cluster whose creation failed
+// when the spec selects other ones: they can be changed until the kind cluster is created
+func followFailedPlacement(kindCluster *infrastructurev1beta1.KindCluster) {
+	if kindCluster.Status.Phase != infrastructurev1beta1.KindClusterPhaseFailed {
+		return
+	}
+	if ref := kindCluster.Spec.HostRef; ref != nil && ref.Name != kindCluster.Status.Host {
+		kindCluster.Status.Host = ""
+	}
+	if kindCluster.Spec.HostRef == nil && kindCluster.Spec.HostSelector == nil {
+		kindCluster.Status.Host = ""
+	}
+	if kindCluster.Spec.Runtime != "" && kindCluster.Spec.Runtime != kindCluster.Status.Runtime {
+		kindCluster.Status.Runtime = ""
+	}
+}
+
+// retryCreateAnnotated passes the updates setting the RetryCreateAnnotation, they do not change the generation
+func retryCreateAnnotated() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			_, before := e.ObjectOld.GetAnnotations()[infrastructurev1beta1.RetryCreateAnnotation]
+			_, after := e.ObjectNew.GetAnnotations()[infrastructurev1beta1.RetryCreateAnnotation]
+			return after && !before
+		},
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	infrastructurev1beta1 "github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/mbovo/cluster-api-provider-kind/pkg/kind"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+)
+
+func TestCreateBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int32
+		want     time.Duration
+	}{
+		{attempts: 1, want: 10 * time.Second},
+		{attempts: 2, want: 20 * time.Second},
+		{attempts: 4, want: 80 * time.Second},
+		{attempts: 6, want: 5 * time.Minute},
+		{attempts: 100, want: 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		g := NewWithT(t)
+		g.Expect(createBackoff(tt.attempts)).To(Equal(tt.want), "attempts %d", tt.attempts)
+	}
+}
+
+func TestCreateRetries(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	r := &KindClusterReconciler{Recorder: record.NewFakeRecorder(100)}
+	kindCluster := &infrastructurev1beta1.KindCluster{Spec: infrastructurev1beta1.KindClusterSpec{MaxCreateAttempts: 2}}
+
+	result := r.createFailed(ctx, kindCluster, errors.New("boom"))
+	g.Expect(result.RequeueAfter).To(Equal(createBackoffBase))
+	g.Expect(kindCluster.Status.CreateAttempts).To(BeEquivalentTo(1))
+	g.Expect(kindCluster.Status.Phase).To(Equal(infrastructurev1beta1.KindClusterPhaseFailed))
+	g.Expect(kindCluster.Status.FailureReason).To(BeNil())
+
+	// held until the backoff expired
+	_, done := r.reconcileCreateFailure(ctx, kindCluster)
+	g.Expect(done).To(BeTrue())
+	kindCluster.Status.LastCreateFailureTime = &metav1.Time{Time: time.Now().Add(-createBackoffBase)}
+	_, done = r.reconcileCreateFailure(ctx, kindCluster)
+	g.Expect(done).To(BeFalse())
+
+	// terminal once the budget is spent
+	result = r.createFailed(ctx, kindCluster, errors.New("boom"))
+	g.Expect(result.IsZero()).To(BeTrue())
+	g.Expect(kindCluster.Status.FailureReason).NotTo(BeNil())
+	kindCluster.Status.LastCreateFailureTime = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+	_, done = r.reconcileCreateFailure(ctx, kindCluster)
+	g.Expect(done).To(BeTrue())
+
+	// until the retry annotation is set
+	kindCluster.Annotations = map[string]string{infrastructurev1beta1.RetryCreateAnnotation: ""}
+	_, done = r.reconcileCreateFailure(ctx, kindCluster)
+	g.Expect(done).To(BeFalse())
+	g.Expect(kindCluster.Annotations).NotTo(HaveKey(infrastructurev1beta1.RetryCreateAnnotation))
+	g.Expect(kindCluster.Status.CreateAttempts).To(BeZero())
+	g.Expect(kindCluster.Status.FailureReason).To(BeNil())
+}
+
+func TestCreateInvalidConfig(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	r := &KindClusterReconciler{Recorder: record.NewFakeRecorder(100)}
+	kindCluster := &infrastructurev1beta1.KindCluster{ObjectMeta: metav1.ObjectMeta{Generation: 3}}
+
+	// terminal at the first attempt
+	result := r.createFailed(ctx, kindCluster, fmt.Errorf("bad apiServerAddress: %w", kind.ErrInvalidConfig))
+	g.Expect(result.IsZero()).To(BeTrue())
+	g.Expect(kindCluster.Status.CreateAttempts).To(BeEquivalentTo(1))
+	g.Expect(kindCluster.Status.FailureReason).NotTo(BeNil())
+	g.Expect(*kindCluster.Status.FailureReason).To(Equal(capierrors.InvalidConfigurationClusterError))
+	g.Expect(kindCluster.Status.FailureGeneration).To(BeEquivalentTo(3))
+	_, done := r.reconcileCreateFailure(ctx, kindCluster)
+	g.Expect(done).To(BeTrue())
+
+	// until the spec changes
+	kindCluster.Generation = 4
+	_, done = r.reconcileCreateFailure(ctx, kindCluster)
+	g.Expect(done).To(BeFalse())
+	g.Expect(kindCluster.Status.CreateAttempts).To(BeZero())
+	g.Expect(kindCluster.Status.FailureReason).To(BeNil())
+	g.Expect(kindCluster.Status.FailureGeneration).To(BeZero())
+}
+
+func TestFollowFailedPlacement(t *testing.T) {
+	g := NewWithT(t)
+	kindCluster := &infrastructurev1beta1.KindCluster{
+		Spec: infrastructurev1beta1.KindClusterSpec{
+			HostRef: &corev1.LocalObjectReference{Name: "build-2"},
+			Runtime: infrastructurev1beta1.PodmanRuntime,
+		},
+		Status: infrastructurev1beta1.KindClusterStatus{Host: "build-1", Runtime: infrastructurev1beta1.DockerRuntime},
+	}
+
+	// kept once the kind cluster is created
+	followFailedPlacement(kindCluster)
+	g.Expect(kindCluster.Status.Host).To(Equal("build-1"))
+	g.Expect(kindCluster.Status.Runtime).To(Equal(infrastructurev1beta1.DockerRuntime))
+
+	// the spec is followed after a failed creation
+	kindCluster.Status.Phase = infrastructurev1beta1.KindClusterPhaseFailed
+	followFailedPlacement(kindCluster)
+	g.Expect(kindCluster.Status.Host).To(BeEmpty())
+	g.Expect(kindCluster.Status.Runtime).To(BeEmpty())
+}
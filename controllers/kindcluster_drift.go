@@ -0,0 +1,134 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	infrastructurev1beta1 "github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/mbovo/cluster-api-provider-kind/pkg/kind"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// interval between two comparisons of the node containers of a ready kind cluster with its spec,
+// and between two remediation attempts
+const driftCheckInterval = time.Minute
+
+// reconcileDrift compares the node containers of the kind cluster with its spec. Stopped or missing nodes make
+// the kind cluster degraded, they are restarted or recreated in background according to Spec.Remediation.
+// A non zero result is returned when the kind cluster is degraded.
+func (r *KindClusterReconciler) reconcileDrift(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster) (reconcile.Result, error) {
+	logger := log.FromContext(ctx)
+	clusterName := kind.ClusterName(kindCluster)
+
+	drift, err := r.kindHelper.NodeDrift(ctx, kindCluster)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if !drift.Drifted() {
+		conditions.MarkTrue(kindCluster, infrastructurev1beta1.NodesHealthyCondition)
+		return reconcile.Result{}, nil
+	}
+
+	reason, severity := infrastructurev1beta1.NodesStoppedReason, clusterv1.ConditionSeverityWarning
+	if len(drift.MissingControlPlane) > 0 || len(drift.MissingWorkers) > 0 || len(drift.MissingScaledWorkers) > 0 {
+		reason, severity = infrastructurev1beta1.NodesMissingReason, clusterv1.ConditionSeverityError
+	}
+	if kindCluster.Status.Phase != infrastructurev1beta1.KindClusterPhaseDegraded {
+		logger.Info("Kind cluster degraded", "cluster", clusterName, "drift", drift.String())
+		r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, reason, "Kind cluster %s degraded: %s", clusterName, drift.String())
+	}
+	kindCluster.Status.Ready = false
+	kindCluster.Status.Phase = infrastructurev1beta1.KindClusterPhaseDegraded
+
+	policy := kindCluster.Spec.Remediation
+	rebuild := policy == infrastructurev1beta1.RecreateRemediationPolicy && len(drift.MissingControlPlane) > 0
+	// the workers of spec.workerCount are added back whatever the policy, as when scaling: their Kubernetes node
+	// left behind would keep the kind cluster unhealthy, and unhealthy clusters are not scaled
+	var toStart []string
+	toRecreate := drift.MissingScaledWorkers
+	if policy == infrastructurev1beta1.RestartRemediationPolicy || policy == infrastructurev1beta1.RecreateRemediationPolicy {
+		toStart = drift.Stopped
+	}
+	if policy == infrastructurev1beta1.RecreateRemediationPolicy {
+		toRecreate = append(append([]string{}, drift.MissingWorkers...), drift.MissingScaledWorkers...)
+	}
+	if !rebuild && len(toStart) == 0 && len(toRecreate) == 0 {
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.NodesHealthyCondition, reason, severity, drift.String())
+		return reconcile.Result{RequeueAfter: driftCheckInterval}, nil
+	}
+
+	logger.Info("Remediating kind cluster", "cluster", clusterName, "policy", policy, "drift", drift.String())
+	conditions.MarkFalse(kindCluster, infrastructurev1beta1.NodesHealthyCondition, infrastructurev1beta1.RemediatingReason, clusterv1.ConditionSeverityInfo,
+		"remediating %s", drift.String())
+	helper, toRemediate := r.kindHelper, kindCluster.DeepCopy()
+	if rebuild {
+		// the kubeconfig Secret is kept and updated once the new cluster is up, the workloads are lost
+		r.Recorder.Eventf(kindCluster, corev1.EventTypeNormal, "Remediating", "Creating kind cluster %s again", clusterName)
+		kindCluster.Status.ControlPlaneNodes = kind.ControlPlaneCount(kindCluster)
+		kindCluster.Status.WorkerNodes = kindCluster.Spec.WorkerCount
+		kindCluster.Status.Image = kind.NodeImage(kindCluster)
+		kindCluster.Status.ControlPlaneImage = ""
+		r.operations.Start(kindCluster.UID, operationRebuild, onHost(ctx, helper, func() error {
+			if err := helper.Delete(ctx, toRemediate); err != nil {
+				return err
+			}
+			return helper.Create(ctx, toRemediate)
+		}))
+		return reconcile.Result{RequeueAfter: operationPollInterval}, nil
+	}
+	r.Recorder.Eventf(kindCluster, corev1.EventTypeNormal, "Remediating", "Repairing kind cluster %s: %s", clusterName, drift.String())
+	r.operations.Start(kindCluster.UID, operationRepair, onHost(ctx, helper, func() error {
+		if err := helper.StartNodes(ctx, toRemediate, toStart); err != nil {
+			return err
+		}
+		for _, name := range toRecreate {
+			if err := helper.RecreateWorker(ctx, toRemediate, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	return reconcile.Result{RequeueAfter: operationPollInterval}, nil
+}
+
+// completeRemediation reports the result of a completed remediation operation. It returns false when the
+// remediation failed, the next attempt is delayed.
+func (r *KindClusterReconciler) completeRemediation(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster, op *operation) bool {
+	logger := log.FromContext(ctx)
+
+	if err := op.Err(); err != nil {
+		logger.Error(err, "Failed to remediate kind cluster", "operation", op.action)
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.NodesHealthyCondition, infrastructurev1beta1.RemediationFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, "RemediationFailed", "Failed to remediate kind cluster (%s): %v", op.action, err)
+		return false
+	}
+
+	r.Recorder.Eventf(kindCluster, corev1.EventTypeNormal, "Remediated", "Kind cluster remediated (%s)", op.action)
+	// new nodes have none of the preloaded images
+	kindCluster.Status.PreloadedImages = nil
+	if op.action == operationRebuild {
+		kindCluster.Status.Phase = infrastructurev1beta1.KindClusterPhaseWaitingForControlPlane
+	}
+	return true
+}
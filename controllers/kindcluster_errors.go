@@ -0,0 +1,74 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	infrastructurev1beta1 "github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/mbovo/cluster-api-provider-kind/pkg/kind"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// kindError maps an error of the kind helper to the conditions, events and requeue of the KindCluster, see the
+// errors of pkg/kind. Transient errors are waited for without backing off, the kind cluster is never taken as
+// missing while the runtime is unavailable. Other errors are returned, retried with backoff.
+func (r *KindClusterReconciler) kindError(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster, err error) (reconcile.Result, error) {
+	logger := log.FromContext(ctx)
+	clusterName := kind.ClusterName(kindCluster)
+
+	switch {
+	case errors.Is(err, kind.ErrRuntimeUnavailable):
+		logger.Info("Container runtime unavailable", "reason", err.Error())
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.RuntimeAvailableCondition, infrastructurev1beta1.RuntimeUnavailableReason, clusterv1.ConditionSeverityError, err.Error())
+		r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, "RuntimeUnavailable", "Container runtime unavailable: %v", err)
+		kindCluster.Status.Ready = false
+		return reconcile.Result{RequeueAfter: time.Minute}, nil
+
+	case errors.Is(err, kind.ErrClusterNotFound):
+		// created again by the next reconciliation
+		logger.Info("Kind cluster not found", "cluster", clusterName, "reason", err.Error())
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.KindClusterCreatedCondition, infrastructurev1beta1.KindClusterNotFoundReason, clusterv1.ConditionSeverityWarning, err.Error())
+		r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, "NotFound", "Kind cluster %s not found", clusterName)
+		kindCluster.Status.Ready = false
+		return reconcile.Result{RequeueAfter: operationPollInterval}, nil
+
+	case errors.Is(err, kind.ErrEndpointUnavailable):
+		logger.Info("Kind cluster endpoint unavailable", "cluster", clusterName, "reason", err.Error())
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.EndpointResolvedCondition, infrastructurev1beta1.EndpointUnavailableReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return reconcile.Result{RequeueAfter: operationPollInterval}, nil
+
+	case errors.Is(err, kind.ErrInvalidConfig):
+		// a kind cluster that cannot be created fails, until its spec changes, see reconcileCreateFailure
+		if !conditions.IsTrue(kindCluster, infrastructurev1beta1.KindClusterCreatedCondition) {
+			return r.createFailed(ctx, kindCluster, err), nil
+		}
+		// the created kind cluster keeps running, spec changes trigger a reconciliation: not requeued
+		logger.Info("Invalid kind cluster configuration", "cluster", clusterName, "reason", err.Error())
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.UpToDateCondition, infrastructurev1beta1.InvalidConfigurationReason, clusterv1.ConditionSeverityError, err.Error())
+		r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, "InvalidConfiguration", "Invalid kind cluster configuration: %v", err)
+		return reconcile.Result{}, nil
+	}
+	return reconcile.Result{}, err
+}
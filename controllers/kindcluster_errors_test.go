@@ -0,0 +1,103 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	infrastructurev1beta1 "github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/mbovo/cluster-api-provider-kind/pkg/kind"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+func TestKindError(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantRequeue   time.Duration
+		wantErr       bool
+		wantCondition clusterv1.ConditionType
+		wantReason    string
+		created       bool
+		wantFailure   bool
+	}{
+		{
+			name:          "runtime unavailable",
+			err:           errors.Wrap(errors.WithStack(kind.ErrRuntimeUnavailable), "failed to list clusters"),
+			wantRequeue:   time.Minute,
+			wantCondition: infrastructurev1beta1.RuntimeAvailableCondition,
+			wantReason:    infrastructurev1beta1.RuntimeUnavailableReason,
+		},
+		{
+			name:          "cluster not found",
+			err:           kind.ErrClusterNotFound,
+			wantRequeue:   operationPollInterval,
+			wantCondition: infrastructurev1beta1.KindClusterCreatedCondition,
+			wantReason:    infrastructurev1beta1.KindClusterNotFoundReason,
+		},
+		{
+			name:          "endpoint unavailable",
+			err:           kind.ErrEndpointUnavailable,
+			wantRequeue:   operationPollInterval,
+			wantCondition: infrastructurev1beta1.EndpointResolvedCondition,
+			wantReason:    infrastructurev1beta1.EndpointUnavailableReason,
+		},
+		{
+			name:        "invalid config of a kind cluster to create",
+			err:         kind.ErrInvalidConfig,
+			wantFailure: true,
+		},
+		{
+			name:          "invalid config of a created kind cluster",
+			err:           errors.Wrap(kind.ErrInvalidConfig, "failed to parse kubeadm config patch"),
+			created:       true,
+			wantCondition: infrastructurev1beta1.UpToDateCondition,
+			wantReason:    infrastructurev1beta1.InvalidConfigurationReason,
+		},
+		{
+			name:    "other",
+			err:     errors.New("boom"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			r := &KindClusterReconciler{Recorder: record.NewFakeRecorder(10)}
+			kindCluster := &infrastructurev1beta1.KindCluster{Status: infrastructurev1beta1.KindClusterStatus{Ready: true}}
+			if tt.created {
+				conditions.MarkTrue(kindCluster, infrastructurev1beta1.KindClusterCreatedCondition)
+			}
+
+			result, err := r.kindError(context.Background(), kindCluster, tt.err)
+			g.Expect(err != nil).To(Equal(tt.wantErr))
+			g.Expect(result.RequeueAfter).To(Equal(tt.wantRequeue))
+			if tt.wantCondition != "" {
+				g.Expect(conditions.IsFalse(kindCluster, tt.wantCondition)).To(BeTrue())
+				g.Expect(conditions.GetReason(kindCluster, tt.wantCondition)).To(Equal(tt.wantReason))
+			}
+			g.Expect(kindCluster.Status.FailureReason != nil).To(Equal(tt.wantFailure))
+		})
+	}
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	infrastructurev1beta1 "github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// how long the health probes of a kind cluster may take
+const healthProbeTimeout = 10 * time.Second
+
+// systemComponent is a kube-system pod of every kind cluster, selected by label
+type systemComponent struct {
+	label string
+	value string
+}
+
+var (
+	controlPlaneComponents = []systemComponent{
+		{"component", "etcd"},
+		{"component", "kube-apiserver"},
+		{"component", "kube-controller-manager"},
+		{"component", "kube-scheduler"},
+	}
+	// CoreDNS needs a CNI to run
+	dnsComponent       = systemComponent{"k8s-app", "kube-dns"}
+	kubeProxyComponent = systemComponent{"k8s-app", "kube-proxy"}
+)
+
+// reconcileHealth probes the kind cluster with its kubeconfig: the API server must be ready, all the nodes
+// registered and Ready and the system pods Ready. Status.Nodes is set to the Ready nodes.
+// Without the default CNI the nodes are not Ready, and CoreDNS not running, until a CNI is installed: then only
+// the registration of the nodes and the control plane pods are checked.
+func (r *KindClusterReconciler) reconcileHealth(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster) (bool, error) {
+	logger := log.FromContext(ctx)
+
+	raw, err := r.kindHelper.KubeConfig(ctx, kindCluster)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get kind cluster kubeconfig")
+	}
+	restConfig, err := clientcmd.RESTConfigFromKubeConfig(raw)
+	if err != nil {
+		return false, err
+	}
+	restConfig.Timeout = healthProbeTimeout
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return false, err
+	}
+	expected, err := r.kindHelper.CountNodes(ctx, kindCluster)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+
+	if _, err := clientset.Discovery().RESTClient().Get().AbsPath("/readyz").DoRaw(ctx); err != nil {
+		logger.Info("API server not ready", "reason", err.Error())
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.APIServerHealthyCondition, infrastructurev1beta1.APIServerUnhealthyReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return false, nil
+	}
+	conditions.MarkTrue(kindCluster, infrastructurev1beta1.APIServerHealthyCondition)
+
+	withCNI := !kindCluster.Spec.Networking.DisableDefaultCNI
+	ready, reason, err := nodesHealth(ctx, clientset, expected, withCNI)
+	if err != nil {
+		return false, err
+	}
+	kindCluster.Status.Nodes = ready
+	if reason != "" {
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.NodesReadyCondition, infrastructurev1beta1.NodesNotReadyReason, clusterv1.ConditionSeverityWarning, reason)
+	} else {
+		conditions.MarkTrue(kindCluster, infrastructurev1beta1.NodesReadyCondition)
+	}
+
+	components := append([]systemComponent{}, controlPlaneComponents...)
+	components = append(components, kubeProxyComponent)
+	if withCNI {
+		components = append(components, dnsComponent)
+	}
+	podsReason, err := systemPodsHealth(ctx, clientset, components)
+	if err != nil {
+		return false, err
+	}
+	if podsReason != "" {
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.SystemPodsReadyCondition, infrastructurev1beta1.SystemPodsNotReadyReason, clusterv1.ConditionSeverityWarning, podsReason)
+	} else {
+		conditions.MarkTrue(kindCluster, infrastructurev1beta1.SystemPodsReadyCondition)
+	}
+
+	if reason != "" || podsReason != "" {
+		logger.Info("Kind cluster not healthy", "nodes", reason, "pods", podsReason)
+		return false, nil
+	}
+	return true, nil
+}
+
+// nodesHealth returns the number of Ready nodes, with the reason the nodes are not healthy if any:
+// expected nodes must be registered, and Ready when requireReady is set
+func nodesHealth(ctx context.Context, c kubernetes.Interface, expected int32, requireReady bool) (int32, string, error) {
+	nodes, err := c.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, "", errors.Wrap(err, "failed to list nodes")
+	}
+
+	ready := int32(0)
+	var notReady []string
+	for i := range nodes.Items {
+		if nodeReady(&nodes.Items[i]) {
+			ready++
+		} else {
+			notReady = append(notReady, nodes.Items[i].Name)
+		}
+	}
+
+	registered := int32(len(nodes.Items))
+	switch {
+	case registered < expected:
+		return ready, fmt.Sprintf("%d of %d nodes registered", registered, expected), nil
+	case requireReady && len(notReady) > 0:
+		sort.Strings(notReady)
+		return ready, fmt.Sprintf("%d of %d nodes Ready, not Ready: %s", ready, registered, strings.Join(notReady, ", ")), nil
+	}
+	return ready, "", nil
+}
+
+// nodeReady reports whether the Ready condition of the node is true
+func nodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// systemPodsHealth returns the reason the kube-system pods of the given components are not healthy if any:
+// each component must have pods, all of them Ready
+func systemPodsHealth(ctx context.Context, c kubernetes.Interface, components []systemComponent) (string, error) {
+	var problems []string
+	for _, component := range components {
+		pods, err := c.CoreV1().Pods(metav1.NamespaceSystem).List(ctx, metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("%s=%s", component.label, component.value),
+		})
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to list %s pods", component.value)
+		}
+		if len(pods.Items) == 0 {
+			problems = append(problems, component.value+" missing")
+			continue
+		}
+		ready := 0
+		for i := range pods.Items {
+			if podReady(&pods.Items[i]) {
+				ready++
+			}
+		}
+		if ready < len(pods.Items) {
+			problems = append(problems, fmt.Sprintf("%s %d of %d Ready", component.value, ready, len(pods.Items)))
+		}
+	}
+	return strings.Join(problems, ", "), nil
+}
+
+// podReady reports whether the Ready condition of the pod is true
+func podReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testNode(name string, ready corev1.ConditionStatus) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status:     corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: ready}}},
+	}
+}
+
+func testPod(name string, labels map[string]string, ready corev1.ConditionStatus) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceSystem, Labels: labels},
+		Status:     corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}}},
+	}
+}
+
+func TestNodesHealth(t *testing.T) {
+	tests := []struct {
+		name         string
+		nodes        []runtime.Object
+		expected     int32
+		requireReady bool
+		wantReady    int32
+		wantHealthy  bool
+	}{
+		{
+			name:         "all nodes ready",
+			nodes:        []runtime.Object{testNode("dev-control-plane", corev1.ConditionTrue), testNode("dev-worker", corev1.ConditionTrue)},
+			expected:     2,
+			requireReady: true,
+			wantReady:    2,
+			wantHealthy:  true,
+		},
+		{
+			name:         "node not registered",
+			nodes:        []runtime.Object{testNode("dev-control-plane", corev1.ConditionTrue)},
+			expected:     2,
+			requireReady: true,
+			wantReady:    1,
+		},
+		{
+			name:         "node not ready",
+			nodes:        []runtime.Object{testNode("dev-control-plane", corev1.ConditionTrue), testNode("dev-worker", corev1.ConditionFalse)},
+			expected:     2,
+			requireReady: true,
+			wantReady:    1,
+		},
+		{
+			name:        "nodes without CNI",
+			nodes:       []runtime.Object{testNode("dev-control-plane", corev1.ConditionFalse), testNode("dev-worker", corev1.ConditionFalse)},
+			expected:    2,
+			wantHealthy: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			ready, reason, err := nodesHealth(context.Background(), fake.NewSimpleClientset(tt.nodes...), tt.expected, tt.requireReady)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(ready).To(Equal(tt.wantReady))
+			g.Expect(reason == "").To(Equal(tt.wantHealthy))
+		})
+	}
+}
+
+func TestSystemPodsHealth(t *testing.T) {
+	etcd := map[string]string{"component": "etcd"}
+	dns := map[string]string{"k8s-app": "kube-dns"}
+	components := []systemComponent{{"component", "etcd"}, dnsComponent}
+
+	tests := []struct {
+		name        string
+		pods        []runtime.Object
+		wantHealthy bool
+	}{
+		{
+			name:        "all pods ready",
+			pods:        []runtime.Object{testPod("etcd", etcd, corev1.ConditionTrue), testPod("coredns-1", dns, corev1.ConditionTrue), testPod("coredns-2", dns, corev1.ConditionTrue)},
+			wantHealthy: true,
+		},
+		{
+			name: "pod not ready",
+			pods: []runtime.Object{testPod("etcd", etcd, corev1.ConditionTrue), testPod("coredns-1", dns, corev1.ConditionTrue), testPod("coredns-2", dns, corev1.ConditionFalse)},
+		},
+		{
+			name: "component missing",
+			pods: []runtime.Object{testPod("etcd", etcd, corev1.ConditionTrue)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			reason, err := systemPodsHealth(context.Background(), fake.NewSimpleClientset(tt.pods...), components)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(reason == "").To(Equal(tt.wantHealthy))
+		})
+	}
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	infrastructurev1beta1 "github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/mbovo/cluster-api-provider-kind/pkg/kind"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// reconcileImport adopts the existing kind cluster of a KindCluster with Spec.Import, whether the controller created
+// it or not, and records its nodes and image in the status. The kind cluster is never created: a non zero result is
+// returned while it does not exist.
+func (r *KindClusterReconciler) reconcileImport(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster, exists bool) (reconcile.Result, error) {
+	logger := log.FromContext(ctx)
+	clusterName := kind.ClusterName(kindCluster)
+
+	if !exists {
+		logger.Info("Kind cluster to import not found", "cluster", clusterName)
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.KindClusterCreatedCondition, infrastructurev1beta1.KindClusterImportPendingReason, clusterv1.ConditionSeverityWarning,
+			"kind cluster %s to import not found", clusterName)
+		kindCluster.Status.Ready = false
+		// the kind cluster may be created later with the kind CLI
+		return reconcile.Result{RequeueAfter: time.Minute}, nil
+	}
+
+	topology, err := r.kindHelper.Topology(ctx, kindCluster)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if !kindClusterStarted(kindCluster) {
+		logger.Info("Kind cluster imported", "cluster", clusterName, "controlPlanes", topology.ControlPlanes, "workers", topology.Workers, "image", topology.Image)
+		r.Recorder.Eventf(kindCluster, corev1.EventTypeNormal, "Imported", "Kind cluster %s imported, %d control plane and %d worker nodes running %s",
+			clusterName, topology.ControlPlanes, topology.Workers, topology.Image)
+		kindCluster.Status.Phase = infrastructurev1beta1.KindClusterPhaseWaitingForControlPlane
+	}
+	kindCluster.Status.ControlPlaneNodes = topology.ControlPlanes
+	kindCluster.Status.WorkerNodes = topology.Workers
+	kindCluster.Status.Image = topology.Image
+	return reconcile.Result{}, nil
+}
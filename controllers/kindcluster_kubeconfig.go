@@ -0,0 +1,107 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	infrastructurev1beta1 "github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/mbovo/cluster-api-provider-kind/pkg/kubeconfig"
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/secret"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+
+// reconcileKubeconfig publishes the kubeconfig of the kind cluster in the <cluster>-kubeconfig Secret
+// expected by Cluster API, owned by the Cluster. The Secret is updated whenever the kind cluster changes (e.g. it is recreated).
+func (r *KindClusterReconciler) reconcileKubeconfig(ctx context.Context, cluster *clusterv1.Cluster, kindCluster *infrastructurev1beta1.KindCluster) error {
+	logger := log.FromContext(ctx)
+
+	raw, err := r.kindHelper.KubeConfig(ctx, kindCluster)
+	if err != nil {
+		return errors.Wrap(err, "failed to get kind cluster kubeconfig")
+	}
+
+	cfg, err := kubeconfig.Decode(raw)
+	if err != nil {
+		return err
+	}
+	server := fmt.Sprintf("https://%s", kindCluster.Spec.ControlPlaneEndpoint.String())
+	if err := kubeconfig.Rewrite(cfg, cluster.Name, server); err != nil {
+		return err
+	}
+	data, err := kubeconfig.Encode(cfg)
+	if err != nil {
+		return err
+	}
+
+	kubeconfigSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secret.Name(cluster.Name, secret.Kubeconfig),
+			Namespace: cluster.Namespace,
+		},
+	}
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, kubeconfigSecret, func() error {
+		if kubeconfigSecret.Labels == nil {
+			kubeconfigSecret.Labels = map[string]string{}
+		}
+		kubeconfigSecret.Labels[clusterv1.ClusterLabelName] = cluster.Name
+		kubeconfigSecret.OwnerReferences = util.EnsureOwnerRef(kubeconfigSecret.OwnerReferences, metav1.OwnerReference{
+			APIVersion: clusterv1.GroupVersion.String(),
+			Kind:       "Cluster",
+			Name:       cluster.Name,
+			UID:        cluster.UID,
+		})
+		kubeconfigSecret.Type = clusterv1.ClusterSecretType
+		kubeconfigSecret.Data = map[string][]byte{
+			secret.KubeconfigDataName: data,
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to publish kubeconfig secret")
+	}
+	if result != controllerutil.OperationResultNone {
+		logger.Info("Kubeconfig secret published", "secret", kubeconfigSecret.Name, "operation", result)
+	}
+
+	return nil
+}
+
+// deleteKubeconfig removes the <cluster>-kubeconfig Secret of the given Cluster, if any.
+func (r *KindClusterReconciler) deleteKubeconfig(ctx context.Context, cluster *clusterv1.Cluster) error {
+	kubeconfigSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secret.Name(cluster.Name, secret.Kubeconfig),
+			Namespace: cluster.Namespace,
+		},
+	}
+	if err := r.Client.Delete(ctx, kubeconfigSecret); err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to delete kubeconfig secret")
+	}
+	return nil
+}
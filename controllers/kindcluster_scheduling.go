@@ -0,0 +1,209 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	infrastructurev1beta1 "github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// defaultNodeResources are the CPU and memory of a kind node, see KindHostSpec.NodeResources
+var defaultNodeResources = corev1.ResourceList{
+	corev1.ResourceCPU:    resource.MustParse("1"),
+	corev1.ResourceMemory: resource.MustParse("2Gi"),
+}
+
+// scheduledHosts remembers the hosts chosen for the KindClusters until the cache reports them in Status.Host,
+// so that KindClusters scheduled back to back count the ones scheduled before them
+type scheduledHosts struct {
+	mu    sync.Mutex
+	hosts map[types.UID]string
+}
+
+func newScheduledHosts() *scheduledHosts {
+	return &scheduledHosts{hosts: map[types.UID]string{}}
+}
+
+// apply sets the hosts chosen for the given KindClusters of the cache when their status does not report them yet,
+// and forgets the hosts the cache reports. It must be called with the lock held.
+func (s *scheduledHosts) apply(kindClusters []infrastructurev1beta1.KindCluster) {
+	listed := map[types.UID]bool{}
+	for i := range kindClusters {
+		kindCluster := &kindClusters[i]
+		host, ok := s.hosts[kindCluster.UID]
+		if !ok {
+			continue
+		}
+		listed[kindCluster.UID] = true
+		if kindCluster.Status.Host != "" {
+			delete(s.hosts, kindCluster.UID)
+			continue
+		}
+		kindCluster.Status.Host = host
+	}
+	// deleted KindClusters
+	for uid := range s.hosts {
+		if !listed[uid] {
+			delete(s.hosts, uid)
+		}
+	}
+}
+
+// reconcileHostScheduling chooses the KindHost of a KindCluster with Spec.HostSelector and records it in Status.Host,
+// the kind cluster waits for a host with room for its nodes otherwise. KindClusters are scheduled one at a time
+// and the chosen host is persisted before the kind cluster is created.
+func (r *KindClusterReconciler) reconcileHostScheduling(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster) (reconcile.Result, error) {
+	logger := log.FromContext(ctx)
+
+	r.scheduled.mu.Lock()
+	defer r.scheduled.mu.Unlock()
+
+	hosts := &infrastructurev1beta1.KindHostList{}
+	if err := r.Client.List(ctx, hosts, client.InNamespace(kindCluster.Namespace)); err != nil {
+		return reconcile.Result{}, err
+	}
+	kindClusters := &infrastructurev1beta1.KindClusterList{}
+	if err := r.Client.List(ctx, kindClusters, client.InNamespace(kindCluster.Namespace)); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	r.scheduled.apply(kindClusters.Items)
+	host, reason, err := scheduleHost(kindCluster, hosts.Items, kindClusters.Items)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if host == "" {
+		logger.Info("No container host available", "reason", reason)
+		conditions.MarkFalse(kindCluster, infrastructurev1beta1.HostScheduledCondition, infrastructurev1beta1.NoHostAvailableReason, clusterv1.ConditionSeverityWarning, reason)
+		r.Recorder.Eventf(kindCluster, corev1.EventTypeWarning, "NoHostAvailable", "No container host available: %s", reason)
+		// hosts may be added, or free up room
+		return reconcile.Result{RequeueAfter: time.Minute}, nil
+	}
+
+	logger.Info("Container host scheduled", "host", host)
+	r.Recorder.Eventf(kindCluster, corev1.EventTypeNormal, "HostScheduled", "Kind cluster scheduled on KindHost %s", host)
+	kindCluster.Status.Host = host
+	conditions.MarkTrue(kindCluster, infrastructurev1beta1.HostScheduledCondition)
+	if err := r.patcher.Patch(ctx, kindCluster); err != nil {
+		return reconcile.Result{}, err
+	}
+	r.scheduled.hosts[kindCluster.UID] = host
+	return reconcile.Result{}, nil
+}
+
+// scheduleHost returns the ready KindHost matching the host selector of the KindCluster with the most room left
+// for kind nodes once the nodes of the KindCluster are added, given the KindClusters of the namespace.
+// When no host is available the reason is returned instead.
+func scheduleHost(kindCluster *infrastructurev1beta1.KindCluster, hosts []infrastructurev1beta1.KindHost, kindClusters []infrastructurev1beta1.KindCluster) (string, string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(kindCluster.Spec.HostSelector)
+	if err != nil {
+		return "", "", err
+	}
+	nodes := kindClusterNodes(kindCluster)
+
+	best, bestRoom := "", int64(0)
+	var rejected []string
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Name < hosts[j].Name })
+	for i := range hosts {
+		host := &hosts[i]
+		if !selector.Matches(labels.Set(host.Labels)) {
+			continue
+		}
+		if !host.DeletionTimestamp.IsZero() || !host.Status.Ready || host.Status.Capacity == nil {
+			rejected = append(rejected, fmt.Sprintf("%s (not ready)", host.Name))
+			continue
+		}
+
+		// the nodes of the kind clusters not created yet are not running containers of the host yet
+		clusters, pending := int32(0), int32(0)
+		for j := range kindClusters {
+			other := &kindClusters[j]
+			if other.UID == kindCluster.UID || kindClusterHostName(other) != host.Name {
+				continue
+			}
+			clusters++
+			if other.Status.Phase == "" || other.Status.Phase == infrastructurev1beta1.KindClusterPhaseProvisioning {
+				pending += kindClusterNodes(other)
+			}
+		}
+		if host.Spec.MaxClusters != nil && clusters >= *host.Spec.MaxClusters {
+			rejected = append(rejected, fmt.Sprintf("%s (%d clusters, max %d)", host.Name, clusters, *host.Spec.MaxClusters))
+			continue
+		}
+
+		room := hostNodeCapacity(host) - int64(host.Status.Capacity.RunningContainers) - int64(pending) - int64(nodes)
+		if room < 0 {
+			rejected = append(rejected, fmt.Sprintf("%s (no room for %d nodes)", host.Name, nodes))
+			continue
+		}
+		if best == "" || room > bestRoom {
+			best, bestRoom = host.Name, room
+		}
+	}
+
+	if best != "" {
+		return best, "", nil
+	}
+	if len(rejected) == 0 {
+		return "", "no KindHost matches spec.hostSelector", nil
+	}
+	return "", "no KindHost has room for the kind cluster: " + strings.Join(rejected, ", "), nil
+}
+
+// hostNodeCapacity returns the number of kind nodes the CPU and the memory of the host can run
+func hostNodeCapacity(host *infrastructurev1beta1.KindHost) int64 {
+	nodeCPU, nodeMemory := defaultNodeResources[corev1.ResourceCPU], defaultNodeResources[corev1.ResourceMemory]
+	if cpu, ok := host.Spec.NodeResources[corev1.ResourceCPU]; ok && cpu.Sign() > 0 {
+		nodeCPU = cpu
+	}
+	if memory, ok := host.Spec.NodeResources[corev1.ResourceMemory]; ok && memory.Sign() > 0 {
+		nodeMemory = memory
+	}
+
+	byCPU := int64(host.Status.Capacity.CPUs) * 1000 / nodeCPU.MilliValue()
+	byMemory := host.Status.Capacity.Memory.Value() / nodeMemory.Value()
+	if byMemory < byCPU {
+		return byMemory
+	}
+	return byCPU
+}
+
+// kindClusterNodes returns the number of nodes of the kind cluster, node pools included
+func kindClusterNodes(kindCluster *infrastructurev1beta1.KindCluster) int32 {
+	nodes := kindCluster.Spec.ControlPlaneCount + kindCluster.Spec.WorkerCount
+	for _, pool := range kindCluster.Spec.NodePools {
+		nodes += pool.Replicas
+	}
+	return nodes
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	infrastructurev1beta1 "github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/pointer"
+)
+
+func testKindHost(name string, cpus int32, memory string, running int32) infrastructurev1beta1.KindHost {
+	return infrastructurev1beta1.KindHost{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"pool": "ci"}},
+		Status: infrastructurev1beta1.KindHostStatus{
+			Ready:    true,
+			Capacity: &infrastructurev1beta1.KindHostCapacity{CPUs: cpus, Memory: resource.MustParse(memory), RunningContainers: running},
+		},
+	}
+}
+
+func TestScheduleHost(t *testing.T) {
+	kindCluster := &infrastructurev1beta1.KindCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "dev", UID: "dev"},
+		Spec: infrastructurev1beta1.KindClusterSpec{
+			ControlPlaneCount: 1,
+			WorkerCount:       1,
+			HostSelector:      &metav1.LabelSelector{MatchLabels: map[string]string{"pool": "ci"}},
+		},
+	}
+	onHost := func(name, host string, phase infrastructurev1beta1.KindClusterPhase) infrastructurev1beta1.KindCluster {
+		return infrastructurev1beta1.KindCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(name)},
+			Spec:       infrastructurev1beta1.KindClusterSpec{ControlPlaneCount: 1, WorkerCount: 2},
+			Status:     infrastructurev1beta1.KindClusterStatus{Host: host, Phase: phase},
+		}
+	}
+	unready := testKindHost("build-1", 8, "16Gi", 0)
+	unready.Status.Ready = false
+	full := testKindHost("build-1", 8, "16Gi", 0)
+	full.Spec.MaxClusters = pointer.Int32(1)
+	large := testKindHost("build-1", 8, "16Gi", 0)
+	large.Spec.NodeResources = corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("16Gi")}
+	other := testKindHost("build-9", 64, "256Gi", 0)
+	other.Labels = map[string]string{"pool": "gpu"}
+
+	tests := []struct {
+		name         string
+		hosts        []infrastructurev1beta1.KindHost
+		kindClusters []infrastructurev1beta1.KindCluster
+		wantHost     string
+	}{
+		{
+			name:  "no host",
+			hosts: nil,
+		},
+		{
+			name:  "no matching host",
+			hosts: []infrastructurev1beta1.KindHost{other},
+		},
+		{
+			name:     "most room",
+			hosts:    []infrastructurev1beta1.KindHost{testKindHost("build-1", 4, "8Gi", 0), testKindHost("build-2", 8, "16Gi", 0)},
+			wantHost: "build-2",
+		},
+		{
+			name:     "tie on room",
+			hosts:    []infrastructurev1beta1.KindHost{testKindHost("build-2", 4, "8Gi", 0), testKindHost("build-1", 4, "8Gi", 0)},
+			wantHost: "build-1",
+		},
+		{
+			name:     "memory bound",
+			hosts:    []infrastructurev1beta1.KindHost{testKindHost("build-1", 32, "4Gi", 0), testKindHost("build-2", 16, "8Gi", 0)},
+			wantHost: "build-2",
+		},
+		{
+			name:  "running containers",
+			hosts: []infrastructurev1beta1.KindHost{testKindHost("build-1", 4, "8Gi", 3)},
+		},
+		{
+			name:         "pending kind clusters",
+			hosts:        []infrastructurev1beta1.KindHost{testKindHost("build-1", 4, "8Gi", 0)},
+			kindClusters: []infrastructurev1beta1.KindCluster{onHost("ci", "build-1", infrastructurev1beta1.KindClusterPhaseProvisioning)},
+		},
+		{
+			name:         "running kind clusters are running containers",
+			hosts:        []infrastructurev1beta1.KindHost{testKindHost("build-1", 8, "16Gi", 3)},
+			kindClusters: []infrastructurev1beta1.KindCluster{onHost("ci", "build-1", infrastructurev1beta1.KindClusterPhaseReady)},
+			wantHost:     "build-1",
+		},
+		{
+			name:     "kind cluster itself",
+			hosts:    []infrastructurev1beta1.KindHost{testKindHost("build-1", 2, "4Gi", 0)},
+			wantHost: "build-1",
+			kindClusters: []infrastructurev1beta1.KindCluster{{
+				ObjectMeta: kindCluster.ObjectMeta,
+				Spec:       kindCluster.Spec,
+				Status:     infrastructurev1beta1.KindClusterStatus{Host: "build-1"},
+			}},
+		},
+		{
+			name:  "not ready",
+			hosts: []infrastructurev1beta1.KindHost{unready},
+		},
+		{
+			name:         "max clusters",
+			hosts:        []infrastructurev1beta1.KindHost{full},
+			kindClusters: []infrastructurev1beta1.KindCluster{onHost("ci", "build-1", infrastructurev1beta1.KindClusterPhaseReady)},
+		},
+		{
+			name:  "node resources",
+			hosts: []infrastructurev1beta1.KindHost{large},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			host, reason, err := scheduleHost(kindCluster, tt.hosts, tt.kindClusters)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(host).To(Equal(tt.wantHost))
+			if tt.wantHost == "" {
+				g.Expect(reason).NotTo(BeEmpty())
+			}
+		})
+	}
+}
+
+func TestScheduledHosts(t *testing.T) {
+	g := NewWithT(t)
+	pending := func(name string) infrastructurev1beta1.KindCluster {
+		return infrastructurev1beta1.KindCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(name)},
+			Spec:       infrastructurev1beta1.KindClusterSpec{ControlPlaneCount: 1, WorkerCount: 1},
+		}
+	}
+	kindCluster := pending("second")
+	kindCluster.Spec.HostSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"pool": "ci"}}
+	// room for 4 nodes on each host, build-1 preferred
+	hosts := []infrastructurev1beta1.KindHost{testKindHost("build-1", 4, "16Gi", 0), testKindHost("build-2", 4, "16Gi", 1)}
+
+	scheduled := newScheduledHosts()
+	scheduled.hosts["first"] = "build-1"
+	scheduled.hosts["deleted"] = "build-2"
+
+	// the cache does not report the host of the KindCluster scheduled before yet
+	kindClusters := []infrastructurev1beta1.KindCluster{pending("first"), kindCluster}
+	scheduled.apply(kindClusters)
+	g.Expect(kindClusters[0].Status.Host).To(Equal("build-1"))
+	g.Expect(scheduled.hosts).To(Equal(map[types.UID]string{"first": "build-1"}))
+
+	host, _, err := scheduleHost(&kindCluster, hosts, kindClusters)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(host).To(Equal("build-2"))
+
+	// forgotten once the cache reports it
+	reported := pending("first")
+	reported.Status.Host = "build-1"
+	scheduled.apply([]infrastructurev1beta1.KindCluster{reported})
+	g.Expect(scheduled.hosts).To(BeEmpty())
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	infrastructurev1beta1 "github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	imageV125 = "kindest/node:v1.25.3"
+	imageV126 = "kindest/node:v1.26.0"
+	imageV127 = "kindest/node:v1.27.1"
+)
+
+func TestImageKubeVersion(t *testing.T) {
+	g := NewWithT(t)
+	// no kind helper: the version recorded for the image must be used
+	r := &KindClusterReconciler{}
+	kindCluster := &infrastructurev1beta1.KindCluster{Status: infrastructurev1beta1.KindClusterStatus{
+		TargetImage: &infrastructurev1beta1.NodeImageVersion{Image: imageV126, Version: "v1.26.0"},
+	}}
+
+	version, err := r.imageKubeVersion(context.Background(), kindCluster, imageV126)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(version).To(Equal("v1.26.0"))
+}
+
+func TestCompleteUpgrade(t *testing.T) {
+	tests := []struct {
+		name                  string
+		action                operationAction
+		controlPlaneImage     string
+		from, to              string
+		wantControlPlaneImage string
+	}{
+		{name: "rolling update", action: operationUpgrade, from: imageV125, to: imageV126, wantControlPlaneImage: imageV125},
+		{name: "second rolling update", action: operationUpgrade, controlPlaneImage: imageV125, from: imageV126, to: imageV127, wantControlPlaneImage: imageV125},
+		{name: "rolling update back to the control plane image", action: operationUpgrade, controlPlaneImage: imageV125, from: imageV126, to: imageV125},
+		{name: "recreate", action: operationRecreate, controlPlaneImage: imageV125, from: imageV126, to: imageV127},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			r := &KindClusterReconciler{Recorder: record.NewFakeRecorder(10)}
+			kindCluster := &infrastructurev1beta1.KindCluster{Status: infrastructurev1beta1.KindClusterStatus{
+				Image:             tt.from,
+				ControlPlaneImage: tt.controlPlaneImage,
+				Upgrade:           &infrastructurev1beta1.KindClusterUpgradeStatus{FromImage: tt.from, ToImage: tt.to},
+			}}
+			op := &operation{action: tt.action, done: make(chan struct{})}
+			close(op.done)
+
+			r.completeUpgrade(context.Background(), kindCluster, op)
+			g.Expect(kindCluster.Status.Image).To(Equal(tt.to))
+			g.Expect(kindCluster.Status.ControlPlaneImage).To(Equal(tt.wantControlPlaneImage))
+			g.Expect(kindCluster.Status.Upgrade).To(BeNil())
+		})
+	}
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	infrastructurev1beta1 "github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/mbovo/cluster-api-provider-kind/pkg/kind"
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// KindHostReconciler reconciles a KindHost object
+type KindHostReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	patcher  *patch.Helper
+}
+
+// interval between two checks of the docker daemon of a KindHost
+const hostCheckInterval = time.Minute
+
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=kindhosts,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=kindhosts/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=kindhosts/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+// Reconcile checks that the docker daemon of a KindHost answers with the credentials of its Secret, and keeps
+// the KindHost until the KindClusters running on it are deleted.
+func (r *KindHostReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+	logger := log.FromContext(ctx)
+
+	kindHost := &infrastructurev1beta1.KindHost{}
+	if err := r.Client.Get(ctx, req.NamespacedName, kindHost); err != nil {
+		logger.Info(fmt.Sprintf("Failed to get KindHost resource '%s/%s'.", req.NamespacedName.Namespace, req.NamespacedName.Name))
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// set up the patch helper
+	var err error
+	r.patcher, err = patch.NewHelper(kindHost, r.Client)
+	if err != nil {
+		logger.Error(err, "cannot create patch helper")
+		return reconcile.Result{}, err
+	}
+	// Always attempt to patch the KindHost object and status after each reconciliation.
+	defer func() {
+		if err := r.patcher.Patch(ctx, kindHost, patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{
+			infrastructurev1beta1.HostReachableCondition,
+		}}); err != nil {
+			reterr = kerrors.NewAggregate([]error{reterr, err})
+		}
+	}()
+
+	// Handle deleted hosts
+	if !kindHost.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(ctx, kindHost)
+	}
+
+	// Handle non-deleted hosts
+	return r.reconcileNormal(ctx, kindHost)
+}
+
+func (r *KindHostReconciler) reconcileNormal(ctx context.Context, kindHost *infrastructurev1beta1.KindHost) (reconcile.Result, error) {
+	logger := log.FromContext(ctx)
+	logger.Info("Reconciling KindHost")
+
+	controllerutil.AddFinalizer(kindHost, infrastructurev1beta1.KindHostFinalizer)
+	if err := r.patcher.Patch(ctx, kindHost); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	host, err := newHost(ctx, r.Client, kindHost)
+	if err != nil {
+		r.markUnreachable(ctx, kindHost, err)
+		return reconcile.Result{RequeueAfter: hostCheckInterval}, nil
+	}
+
+	release, err := kind.AcquireHost(host)
+	if err != nil {
+		r.markUnreachable(ctx, kindHost, err)
+		return reconcile.Result{RequeueAfter: hostCheckInterval}, nil
+	}
+	defer release()
+
+	info, err := kind.InspectHost(ctx, host)
+	if err != nil {
+		r.markUnreachable(ctx, kindHost, err)
+		return reconcile.Result{RequeueAfter: hostCheckInterval}, nil
+	}
+	if !kindHost.Status.Ready {
+		r.Recorder.Eventf(kindHost, corev1.EventTypeNormal, "HostReachable", "Docker daemon %s reachable", host.Endpoint)
+	}
+	kindHost.Status.Ready = true
+	kindHost.Status.Version = info.Version
+	kindHost.Status.Capacity = &infrastructurev1beta1.KindHostCapacity{
+		CPUs:              info.CPUs,
+		Memory:            *resource.NewQuantity(info.Memory, resource.BinarySI),
+		RunningContainers: info.RunningContainers,
+	}
+	conditions.MarkTrue(kindHost, infrastructurev1beta1.HostReachableCondition)
+
+	kindClusters := &infrastructurev1beta1.KindClusterList{}
+	if err := r.Client.List(ctx, kindClusters, client.InNamespace(kindHost.Namespace)); err != nil {
+		return reconcile.Result{}, err
+	}
+	kindHost.Status.Clusters = 0
+	for i := range kindClusters.Items {
+		if kindClusterHostName(&kindClusters.Items[i]) == kindHost.Name {
+			kindHost.Status.Clusters++
+		}
+	}
+
+	return reconcile.Result{RequeueAfter: hostCheckInterval}, nil
+}
+
+// markUnreachable reports a docker daemon that cannot be reached with the credentials of the KindHost
+func (r *KindHostReconciler) markUnreachable(ctx context.Context, kindHost *infrastructurev1beta1.KindHost, err error) {
+	log.FromContext(ctx).Info("Docker daemon unreachable", "reason", err.Error())
+	conditions.MarkFalse(kindHost, infrastructurev1beta1.HostReachableCondition, infrastructurev1beta1.HostUnreachableReason, clusterv1.ConditionSeverityError, err.Error())
+	if kindHost.Status.Ready {
+		r.Recorder.Eventf(kindHost, corev1.EventTypeWarning, "HostUnreachable", "Docker daemon unreachable: %v", err)
+	}
+	kindHost.Status.Ready = false
+}
+
+func (r *KindHostReconciler) reconcileDelete(ctx context.Context, kindHost *infrastructurev1beta1.KindHost) (reconcile.Result, error) {
+	logger := log.FromContext(ctx)
+	logger.Info("Deleting KindHost")
+
+	// the kind clusters of a host can only be deleted through it
+	kindClusters := &infrastructurev1beta1.KindClusterList{}
+	if err := r.Client.List(ctx, kindClusters, client.InNamespace(kindHost.Namespace)); err != nil {
+		return reconcile.Result{}, err
+	}
+	for i := range kindClusters.Items {
+		if kindClusterHostName(&kindClusters.Items[i]) == kindHost.Name {
+			logger.Info("Waiting for the KindClusters of the host to be deleted", "kindcluster", kindClusters.Items[i].Name)
+			r.Recorder.Eventf(kindHost, corev1.EventTypeNormal, "HostInUse", "KindCluster %s is running on the host", kindClusters.Items[i].Name)
+			return reconcile.Result{RequeueAfter: operationPollInterval}, nil
+		}
+	}
+
+	// the credentials of the host are only written while it is in use
+	if err := kind.ForgetHost(kindHost.Namespace + "/" + kindHost.Name); err != nil {
+		return reconcile.Result{}, err
+	}
+	controllerutil.RemoveFinalizer(kindHost, infrastructurev1beta1.KindHostFinalizer)
+	return reconcile.Result{}, nil
+}
+
+// kindClusterHostName returns the name of the KindHost of the KindCluster, empty for the container host of the
+// controller. The host recorded in the status wins, as the runtime, so that a kind cluster is always managed
+// on the host it has been created on.
+func kindClusterHostName(kindCluster *infrastructurev1beta1.KindCluster) string {
+	if kindCluster.Status.Host != "" {
+		return kindCluster.Status.Host
+	}
+	if kindCluster.Spec.HostRef != nil {
+		return kindCluster.Spec.HostRef.Name
+	}
+	return ""
+}
+
+// kindClusterHost returns the remote container host of the KindCluster, nil for the container host of the controller
+func kindClusterHost(ctx context.Context, c client.Client, kindCluster *infrastructurev1beta1.KindCluster) (*kind.Host, error) {
+	name := kindClusterHostName(kindCluster)
+	if name == "" {
+		return nil, nil
+	}
+	kindHost := &infrastructurev1beta1.KindHost{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: kindCluster.Namespace, Name: name}, kindHost); err != nil {
+		return nil, errors.Wrapf(err, "cannot get KindHost %s", name)
+	}
+	return newHost(ctx, c, kindHost)
+}
+
+// newHost returns the container host of the KindHost, with the credentials of its Secret
+func newHost(ctx context.Context, c client.Client, kindHost *infrastructurev1beta1.KindHost) (*kind.Host, error) {
+	secret := &corev1.Secret{}
+	secretName := types.NamespacedName{Namespace: kindHost.Namespace, Name: kindHost.Spec.SecretRef.Name}
+	if err := c.Get(ctx, secretName, secret); err != nil {
+		return nil, errors.Wrapf(err, "cannot get Secret %s of KindHost %s", secretName.Name, kindHost.Name)
+	}
+	return kind.NewHost(kindHost, secret)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *KindHostReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
+	_, err := ctrl.NewControllerManagedBy(mgr).
+		For(&infrastructurev1beta1.KindHost{}).
+		WithEventFilter(filterStatusChanges()).
+		Build(r)
+
+	if err != nil {
+		return errors.Wrap(err, "failed setting up with a controller manager")
+	}
+	return nil
+}
@@ -0,0 +1,389 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	infrastructurev1beta1 "github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/mbovo/cluster-api-provider-kind/pkg/kind"
+	"github.com/mbovo/cluster-api-provider-kind/pkg/nodeimage"
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/predicates"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// KindMachineReconciler reconciles a KindMachine object
+type KindMachineReconciler struct {
+	client.Client
+	Scheme     *runtime.Scheme
+	Recorder   record.EventRecorder
+	patcher    *patch.Helper
+	kindHelper kind.KindHelper
+	operations *operationTracker
+
+	// ConfigMap overriding the node images published by kind, e.g. with mirrors in air-gapped environments
+	NodeImagesConfigMap types.NamespacedName
+
+	// Container runtime of the KindClusters without Spec.Runtime, detected when empty
+	DefaultRuntime infrastructurev1beta1.ContainerRuntime
+}
+
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=kindmachines,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=kindmachines/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=kindmachines/finalizers,verbs=update
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=kindmachinetemplates,verbs=get;list;watch
+//+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines;machines/status,verbs=get;list;watch
+
+// Reconcile creates one kind worker node per KindMachine, joined to the kind cluster of the KindCluster
+// the Machine belongs to, and deletes it with the KindMachine.
+func (r *KindMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+	logger := log.FromContext(ctx)
+
+	kindMachine := &infrastructurev1beta1.KindMachine{}
+	if err := r.Client.Get(ctx, req.NamespacedName, kindMachine); err != nil {
+		logger.Info(fmt.Sprintf("Failed to get KindMachine resource '%s/%s'.", req.NamespacedName.Namespace, req.NamespacedName.Name))
+		return reconcile.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// Fetch the Machine:
+	machine, err := util.GetOwnerMachine(ctx, r.Client, kindMachine.ObjectMeta)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if machine == nil {
+		logger.Info("Machine Controller has not yet set OwnerRef")
+		return reconcile.Result{Requeue: true, RequeueAfter: 5 * time.Second}, nil
+	}
+
+	// Fetch the Cluster:
+	cluster, err := util.GetClusterFromMetadata(ctx, r.Client, machine.ObjectMeta)
+	if err != nil {
+		logger.Info("Machine is missing cluster label or cluster does not exist")
+		return reconcile.Result{}, nil
+	}
+
+	if annotations.IsPaused(cluster, kindMachine) {
+		r.Recorder.Eventf(kindMachine, corev1.EventTypeNormal, "ClusterPaused", "Cluster is paused")
+		logger.Info("KindMachine or linked Cluster is marked as paused, will not reconcile")
+		return reconcile.Result{}, nil
+	}
+
+	// set up the patch helper
+	r.patcher, err = patch.NewHelper(kindMachine, r.Client)
+	if err != nil {
+		logger.Error(err, "cannot create patch helper")
+		return reconcile.Result{}, err
+	}
+	// Always attempt to patch the KindMachine object and status after each reconciliation.
+	defer func() {
+		if err := r.patchKindMachine(ctx, kindMachine); err != nil {
+			reterr = kerrors.NewAggregate([]error{reterr, err})
+		}
+	}()
+
+	// Fetch the KindCluster:
+	if cluster.Spec.InfrastructureRef == nil {
+		logger.Info("Cluster infrastructureRef is not available yet")
+		return reconcile.Result{}, nil
+	}
+	kindCluster := &infrastructurev1beta1.KindCluster{}
+	kindClusterName := types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Spec.InfrastructureRef.Name}
+	if err := r.Client.Get(ctx, kindClusterName, kindCluster); err != nil {
+		if apierrors.IsNotFound(err) && !kindMachine.DeletionTimestamp.IsZero() {
+			// the kind cluster has been deleted with its nodes
+			controllerutil.RemoveFinalizer(kindMachine, infrastructurev1beta1.KindMachineFinalizer)
+			return reconcile.Result{}, nil
+		}
+		logger.Info("KindCluster is not available yet")
+		return reconcile.Result{}, nil
+	}
+
+	// set up kind helper, on the container host and with the container runtime of the kind cluster
+	host, err := kindClusterHost(ctx, r.Client, kindCluster)
+	if err != nil {
+		logger.Info("Container host unavailable", "reason", err.Error())
+		return reconcile.Result{RequeueAfter: time.Minute}, nil
+	}
+	release, err := kind.AcquireHost(host)
+	if err != nil {
+		logger.Info("Container host unavailable", "reason", err.Error())
+		return reconcile.Result{RequeueAfter: time.Minute}, nil
+	}
+	defer release()
+	runtime, err := kind.ResolveRuntime(ctx, kindCluster, r.DefaultRuntime, host)
+	if err != nil {
+		logger.Info("Container runtime unavailable", "reason", err.Error())
+		return reconcile.Result{RequeueAfter: time.Minute}, nil
+	}
+	r.kindHelper = kind.NewKindLibHelper(logger, kindCluster, cluster, runtime, host)
+
+	// Handle deleted machines
+	if !kindMachine.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(ctx, kindCluster, kindMachine)
+	}
+
+	// Handle non-deleted machines
+	return r.reconcileNormal(ctx, cluster, machine, kindCluster, kindMachine)
+}
+
+func (r *KindMachineReconciler) reconcileNormal(ctx context.Context, cluster *clusterv1.Cluster, machine *clusterv1.Machine, kindCluster *infrastructurev1beta1.KindCluster, kindMachine *infrastructurev1beta1.KindMachine) (reconcile.Result, error) {
+	logger := log.FromContext(ctx)
+	logger.Info("Reconciling KindMachine")
+
+	controllerutil.AddFinalizer(kindMachine, infrastructurev1beta1.KindMachineFinalizer)
+	if err := r.patcher.Patch(ctx, kindMachine); err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// the control plane nodes are created with the kind cluster
+	if util.IsControlPlaneMachine(machine) {
+		conditions.MarkFalse(kindMachine, infrastructurev1beta1.NodeProvisionedCondition, infrastructurev1beta1.NodeUnsupportedReason, clusterv1.ConditionSeverityError,
+			"control plane nodes are managed by the KindCluster")
+		failureReason := capierrors.InvalidConfigurationMachineError
+		kindMachine.Status.FailureReason = &failureReason
+		kindMachine.Status.FailureMessage = pointer.String("control plane Machines are not supported, control plane nodes are managed by the KindCluster")
+		r.Recorder.Eventf(kindMachine, corev1.EventTypeWarning, "NodeUnsupported", "Control plane Machines are not supported")
+		return reconcile.Result{}, nil
+	}
+
+	if !cluster.Status.InfrastructureReady {
+		logger.Info("Waiting for KindCluster to be ready")
+		conditions.MarkFalse(kindMachine, infrastructurev1beta1.NodeProvisionedCondition, infrastructurev1beta1.WaitingForClusterInfrastructureReason, clusterv1.ConditionSeverityInfo, "")
+		return reconcile.Result{}, nil
+	}
+
+	// kind joins the node to the cluster itself, the bootstrap data is not used but it must be set as per contract
+	if machine.Spec.Bootstrap.DataSecretName == nil {
+		logger.Info("Waiting for the bootstrap data to be available")
+		conditions.MarkFalse(kindMachine, infrastructurev1beta1.NodeProvisionedCondition, infrastructurev1beta1.WaitingForBootstrapDataReason, clusterv1.ConditionSeverityInfo, "")
+		return reconcile.Result{}, nil
+	}
+
+	nodeName := kindMachine.Status.NodeName
+	if nodeName == "" {
+		nodeName = kind.MachineNodeName(kindCluster, kindMachine)
+		kindMachine.Status.NodeName = nodeName
+	}
+
+	// Kind node creation runs in background, wait for it to complete
+	if op := r.operations.Get(kindMachine.UID); op != nil {
+		if !op.Done() {
+			logger.Info("Kind node creation in progress", "node", nodeName)
+			return reconcile.Result{RequeueAfter: operationPollInterval}, nil
+		}
+		r.operations.Forget(kindMachine.UID)
+		if err := op.Err(); err != nil {
+			conditions.MarkFalse(kindMachine, infrastructurev1beta1.NodeProvisionedCondition, infrastructurev1beta1.NodeProvisionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			r.Recorder.Eventf(kindMachine, corev1.EventTypeWarning, "NodeProvisionFailed", "Failed to create kind node %s: %v", nodeName, err)
+			return reconcile.Result{}, err
+		}
+		logger.Info("Kind node created", "node", nodeName)
+		r.Recorder.Eventf(kindMachine, corev1.EventTypeNormal, "NodeCreated", "Kind node %s created", nodeName)
+		conditions.MarkTrue(kindMachine, infrastructurev1beta1.NodeProvisionedCondition)
+	}
+
+	exists, err := r.kindHelper.NodeExists(ctx, kindCluster, nodeName)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	// Still provisioning but no creation running: the controller has been restarted in the middle of it
+	if exists && conditions.GetReason(kindMachine, infrastructurev1beta1.NodeProvisionedCondition) == infrastructurev1beta1.NodeProvisioningReason {
+		logger.Info("Deleting partially created node", "node", nodeName)
+		if err := r.kindHelper.RemoveWorker(ctx, kindCluster, nodeName); err != nil {
+			return reconcile.Result{}, err
+		}
+		exists = false
+	}
+
+	if !exists {
+		// the node has been created already: it is gone, let the Machine be remediated
+		if conditions.IsTrue(kindMachine, infrastructurev1beta1.NodeProvisionedCondition) {
+			logger.Info("Kind node not found", "node", nodeName)
+			failureReason := capierrors.UpdateMachineError
+			kindMachine.Status.FailureReason = &failureReason
+			kindMachine.Status.FailureMessage = pointer.String(fmt.Sprintf("kind node %s not found", nodeName))
+			kindMachine.Status.Ready = false
+			return reconcile.Result{}, nil
+		}
+
+		image, err := r.machineImage(ctx, kindCluster, kindMachine, machine)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+
+		logger.Info("Creating kind node", "node", nodeName, "image", image)
+		conditions.MarkFalse(kindMachine, infrastructurev1beta1.NodeProvisionedCondition, infrastructurev1beta1.NodeProvisioningReason, clusterv1.ConditionSeverityInfo,
+			"creating kind node %s", nodeName)
+		helper, toJoin, owner := r.kindHelper, kindCluster.DeepCopy(), kindMachine.DeepCopy()
+		r.operations.Start(kindMachine.UID, operationCreate, onHost(ctx, helper, func() error {
+			return helper.AddWorkerNode(ctx, toJoin, owner, nodeName, image)
+		}))
+		return reconcile.Result{RequeueAfter: operationPollInterval}, nil
+	}
+	conditions.MarkTrue(kindMachine, infrastructurev1beta1.NodeProvisionedCondition)
+
+	providerID := kind.ProviderID(kindCluster, nodeName)
+	kindMachine.Spec.ProviderID = &providerID
+
+	addresses, err := r.kindHelper.NodeAddresses(ctx, kindCluster, nodeName)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	kindMachine.Status.Addresses = addresses
+	kindMachine.Status.Ready = true
+
+	return reconcile.Result{}, nil
+}
+
+func (r *KindMachineReconciler) reconcileDelete(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster, kindMachine *infrastructurev1beta1.KindMachine) (reconcile.Result, error) {
+	logger := log.FromContext(ctx)
+	logger.Info("Deleting KindMachine")
+	nodeName := kindMachine.Status.NodeName
+
+	// a kind node creation cannot be interrupted, wait for it to complete
+	if op := r.operations.Get(kindMachine.UID); op != nil {
+		if !op.Done() {
+			logger.Info("Waiting for kind node creation to complete before deleting it", "node", nodeName)
+			return reconcile.Result{RequeueAfter: operationPollInterval}, nil
+		}
+		r.operations.Forget(kindMachine.UID)
+	}
+
+	conditions.MarkFalse(kindMachine, infrastructurev1beta1.NodeProvisionedCondition, infrastructurev1beta1.NodeDeletingReason, clusterv1.ConditionSeverityInfo, "")
+	kindMachine.Status.Ready = false
+
+	if nodeName != "" {
+		// the nodes of a deleted kind cluster are gone with it
+		clusterExists, err := r.kindHelper.Exists(ctx, kindCluster)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		nodeExists := false
+		if clusterExists {
+			if nodeExists, err = r.kindHelper.NodeExists(ctx, kindCluster, nodeName); err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+		if nodeExists {
+			if err := r.kindHelper.RemoveWorker(ctx, kindCluster, nodeName); err != nil {
+				r.Recorder.Eventf(kindMachine, corev1.EventTypeWarning, "DeleteFailed", "Failed to delete kind node %s: %v", nodeName, err)
+				return reconcile.Result{}, err
+			}
+			r.Recorder.Eventf(kindMachine, corev1.EventTypeNormal, "NodeDeleted", "Kind node %s deleted", nodeName)
+		}
+	}
+
+	controllerutil.RemoveFinalizer(kindMachine, infrastructurev1beta1.KindMachineFinalizer)
+
+	return reconcile.Result{}, nil
+}
+
+// machineImage returns the node image of the KindMachine: the one of its spec, the one for the Machine version,
+// empty to use the image the kind cluster is running
+func (r *KindMachineReconciler) machineImage(ctx context.Context, kindCluster *infrastructurev1beta1.KindCluster, kindMachine *infrastructurev1beta1.KindMachine, machine *clusterv1.Machine) (string, error) {
+	if kindMachine.Spec.Image != "" {
+		return kindMachine.Spec.Image, nil
+	}
+	if machine.Spec.Version == nil {
+		return "", nil
+	}
+
+	overrides, err := nodeImageOverrides(ctx, r.Client, r.NodeImagesConfigMap)
+	if err != nil {
+		return "", err
+	}
+	image, err := nodeimage.Resolve(kindCluster.Spec.Image, *machine.Spec.Version, overrides)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot resolve node image for the Machine version")
+	}
+	return image, nil
+}
+
+// patchKindMachine summarizes the KindMachine conditions in the Ready condition and patches the object.
+func (r *KindMachineReconciler) patchKindMachine(ctx context.Context, kindMachine *infrastructurev1beta1.KindMachine) error {
+	conditions.SetSummary(kindMachine,
+		conditions.WithConditions(
+			infrastructurev1beta1.NodeProvisionedCondition,
+		),
+		conditions.WithStepCounterIf(kindMachine.ObjectMeta.DeletionTimestamp.IsZero()),
+	)
+
+	return r.patcher.Patch(ctx, kindMachine,
+		patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{
+			clusterv1.ReadyCondition,
+			infrastructurev1beta1.NodeProvisionedCondition,
+		}},
+	)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *KindMachineReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
+	log := ctrl.LoggerFrom(ctx)
+
+	if r.operations == nil {
+		r.operations = newOperationTracker()
+	}
+
+	controller, err := ctrl.NewControllerManagedBy(mgr).
+		For(&infrastructurev1beta1.KindMachine{}).
+		WithEventFilter(predicates.ResourceNotPaused(log)).
+		Watches(
+			&source.Kind{Type: &clusterv1.Machine{}},
+			handler.EnqueueRequestsFromMapFunc(util.MachineToInfrastructureMapFunc(infrastructurev1beta1.GroupVersion.WithKind("KindMachine"))),
+		).
+		Build(r)
+
+	if err != nil {
+		return errors.Wrap(err, "failed setting up with a controller manager")
+	}
+
+	// Watch for Cluster infrastructure becoming ready to enqueue the KindMachines waiting for it.
+	clusterToKindMachines, err := util.ClusterToObjectsMapper(mgr.GetClient(), &infrastructurev1beta1.KindMachineList{}, mgr.GetScheme())
+	if err != nil {
+		return err
+	}
+	return controller.Watch(
+		&source.Kind{Type: &clusterv1.Cluster{}},
+		handler.EnqueueRequestsFromMapFunc(clusterToKindMachines),
+		predicates.ClusterUnpausedAndInfrastructureReady(log))
+}
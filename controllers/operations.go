@@ -0,0 +1,142 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	infrastructurev1beta1 "github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/mbovo/cluster-api-provider-kind/pkg/kind"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// operationAction is the kind of a background operation
+type operationAction string
+
+const (
+	operationCreate    operationAction = "create"
+	operationScaleUp   operationAction = "scale-up"
+	operationScaleDown operationAction = "scale-down"
+	operationRecreate  operationAction = "recreate"
+	operationUpgrade   operationAction = "upgrade"
+	operationPreload   operationAction = "preload"
+	operationRepair    operationAction = "repair"
+	operationRebuild   operationAction = "rebuild"
+)
+
+// operation is a long running task (e.g. a kind cluster creation) executed in background
+type operation struct {
+	action   operationAction
+	done     chan struct{}
+	err      error
+	progress int32
+	// state of the images of a preload operation
+	preloaded []infrastructurev1beta1.PreloadedImage
+}
+
+// Done reports whether the operation has completed
+func (o *operation) Done() bool {
+	select {
+	case <-o.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Err returns the result of a completed operation
+func (o *operation) Err() error {
+	<-o.done
+	return o.err
+}
+
+// Progress returns the last progress reported by the operation (e.g. the number of upgraded nodes)
+func (o *operation) Progress() int32 {
+	return atomic.LoadInt32(&o.progress)
+}
+
+// operationTracker keeps track of the background operations running for each KindCluster,
+// so that reconcile workers are never blocked waiting for them
+type operationTracker struct {
+	mu  sync.Mutex
+	ops map[types.UID]*operation
+}
+
+func newOperationTracker() *operationTracker {
+	return &operationTracker{ops: map[types.UID]*operation{}}
+}
+
+// Start runs fn in background for the given object, unless an operation is already tracked for it
+func (t *operationTracker) Start(uid types.UID, action operationAction, fn func() error) *operation {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if op, ok := t.ops[uid]; ok {
+		return op
+	}
+	op := &operation{action: action, done: make(chan struct{})}
+	t.ops[uid] = op
+	go func() {
+		defer close(op.done)
+		op.err = fn()
+	}()
+	return op
+}
+
+// Get returns the operation tracked for the given object, nil if none
+func (t *operationTracker) Get(uid types.UID) *operation {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ops[uid]
+}
+
+// SetProgress records the progress of the operation tracked for the given object
+func (t *operationTracker) SetProgress(uid types.UID, progress int32) {
+	if op := t.Get(uid); op != nil {
+		atomic.StoreInt32(&op.progress, progress)
+	}
+}
+
+// SetPreloaded records the state of the images loaded by the preload operation tracked for the given object,
+// it must be called by the operation itself
+func (t *operationTracker) SetPreloaded(uid types.UID, images []infrastructurev1beta1.PreloadedImage) {
+	if op := t.Get(uid); op != nil {
+		op.preloaded = images
+	}
+}
+
+// Forget stops tracking the operation of the given object
+func (t *operationTracker) Forget(uid types.UID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.ops, uid)
+}
+
+// onHost wraps the function of a background operation to keep the container host of the helper acquired until
+// it returns, the reconciliation starting it releases the host before, see kind.AcquireHost
+func onHost(ctx context.Context, helper kind.KindHelper, fn func() error) func() error {
+	return func() error {
+		release, err := helper.AcquireHost()
+		if err != nil {
+			return err
+		}
+		defer release()
+		return fn()
+	}
+}
@@ -19,12 +19,14 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -33,6 +35,7 @@ import (
 
 	infrastructurev1beta1 "github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
 	"github.com/mbovo/cluster-api-provider-kind/controllers"
+	"github.com/mbovo/cluster-api-provider-kind/pkg/kind"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	//+kubebuilder:scaffold:imports
 )
@@ -53,11 +56,19 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var nodeImagesConfigMap string
+	var containerRuntime string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&nodeImagesConfigMap, "node-images-configmap", "",
+		"The <namespace>/<name> of a ConfigMap overriding the kind node images, by Kubernetes version. "+
+			"The key 'repository' replaces the kindest/node repository (e.g. with a mirror).")
+	flag.StringVar(&containerRuntime, "container-runtime", "",
+		"The container runtime of the KindClusters without spec.runtime: docker, podman or nerdctl. "+
+			"Detected when empty, in this order.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -66,6 +77,38 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	// kubeconfigs are kept in memory and in Secrets, previous versions wrote them to /tmp and kind writes them
+	// to private directories while creating or deleting a cluster
+	if err := kind.SweepKubeconfigs(setupLog); err != nil {
+		setupLog.Error(err, "unable to remove stale kubeconfigs")
+	}
+	// the credentials of the container hosts are written while they are in use only
+	if err := kind.SweepHosts(setupLog); err != nil {
+		setupLog.Error(err, "unable to remove stale container host credentials")
+	}
+
+	var nodeImages types.NamespacedName
+	if nodeImagesConfigMap != "" {
+		namespace, name, ok := strings.Cut(nodeImagesConfigMap, "/")
+		if !ok {
+			setupLog.Error(nil, "invalid --node-images-configmap, expected <namespace>/<name>", "value", nodeImagesConfigMap)
+			os.Exit(1)
+		}
+		nodeImages = types.NamespacedName{Namespace: namespace, Name: name}
+	}
+
+	defaultRuntime := infrastructurev1beta1.ContainerRuntime(containerRuntime)
+	if defaultRuntime != "" {
+		supported := false
+		for _, r := range kind.Runtimes {
+			supported = supported || r == defaultRuntime
+		}
+		if !supported {
+			setupLog.Error(nil, "invalid --container-runtime, expected docker, podman or nerdctl", "value", containerRuntime)
+			os.Exit(1)
+		}
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -97,10 +140,38 @@ func main() {
 		Client:   mgr.GetClient(),
 		Scheme:   mgr.GetScheme(),
 		Recorder: mgr.GetEventRecorderFor("kindcluster-controller"),
+
+		NodeImagesConfigMap: nodeImages,
+		DefaultRuntime:      defaultRuntime,
 	}).SetupWithManager(ctx, mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "KindCluster")
 		os.Exit(1)
 	}
+	if err = (&controllers.KindMachineReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("kindmachine-controller"),
+
+		NodeImagesConfigMap: nodeImages,
+		DefaultRuntime:      defaultRuntime,
+	}).SetupWithManager(ctx, mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "KindMachine")
+		os.Exit(1)
+	}
+	if err = (&controllers.KindHostReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("kindhost-controller"),
+	}).SetupWithManager(ctx, mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "KindHost")
+		os.Exit(1)
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&infrastructurev1beta1.KindCluster{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "KindCluster")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -0,0 +1,360 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package featuregates knows the Kubernetes feature gates of the versions kind publishes node images for.
+package featuregates
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+// Stage is the maturity of a feature gate in a Kubernetes version
+type Stage string
+
+const (
+	Alpha Stage = "Alpha"
+	Beta  Stage = "Beta"
+	GA    Stage = "GA"
+)
+
+// minor versions of Kubernetes 1.x the gates table covers: gates of newer versions are not known
+const (
+	minMinor = 19
+	maxMinor = 25
+)
+
+// gate records the Kubernetes 1.x minor versions a feature gate went alpha, beta, GA and was removed in,
+// 0 when it did not (yet)
+type gate struct {
+	alpha, beta, ga, removed int
+}
+
+// feature gates of Kubernetes 1.19 to 1.25, transcribed from the tables of
+// https://kubernetes.io/docs/reference/command-line-tools-reference/feature-gates/ and
+// https://kubernetes.io/docs/reference/command-line-tools-reference/feature-gates-removed/.
+// Gates removed before 1.19 are left out, a stage older than 1.19 is only recorded when it matters.
+// The 1.25 stages of the gates k8s.io/apiserver and k8s.io/component-base v0.25 define are checked by the tests.
+var gates = map[string]gate{
+	"APIListChunking":                                {alpha: 8, beta: 9},
+	"APIPriorityAndFairness":                         {alpha: 18, beta: 20},
+	"APIResponseCompression":                         {alpha: 7, beta: 16},
+	"APIServerIdentity":                              {alpha: 20},
+	"APIServerTracing":                               {alpha: 22},
+	"AdvancedAuditing":                               {alpha: 7, beta: 8, ga: 12},
+	"AllAlpha":                                       {alpha: 1},
+	"AllBeta":                                        {beta: 1},
+	"AllowInsecureBackendProxy":                      {beta: 17},
+	"AnyVolumeDataSource":                            {alpha: 18, beta: 24},
+	"AppArmor":                                       {beta: 4},
+	"BalanceAttachedNodeVolumes":                     {alpha: 11, removed: 22},
+	"BoundServiceAccountTokenVolume":                 {alpha: 13, beta: 21, ga: 22, removed: 24},
+	"CPUCFSQuotaPeriod":                              {alpha: 12},
+	"CPUManager":                                     {alpha: 8, beta: 10},
+	"CPUManagerPolicyAlphaOptions":                   {alpha: 23},
+	"CPUManagerPolicyBetaOptions":                    {beta: 23},
+	"CPUManagerPolicyOptions":                        {alpha: 22, beta: 23},
+	"CRIContainerLogRotation":                        {alpha: 10, beta: 11, ga: 21, removed: 23},
+	"CSIBlockVolume":                                 {alpha: 11, beta: 14, ga: 18, removed: 21},
+	"CSIDriverRegistry":                              {alpha: 12, beta: 14, ga: 18, removed: 21},
+	"CSIInlineVolume":                                {alpha: 15, beta: 16, ga: 25},
+	"CSIMigration":                                   {alpha: 14, beta: 17, ga: 25},
+	"CSIMigrationAWS":                                {alpha: 14, beta: 17, ga: 25},
+	"CSIMigrationAWSComplete":                        {alpha: 17, removed: 22},
+	"CSIMigrationAzureDisk":                          {alpha: 15, beta: 19, ga: 24},
+	"CSIMigrationAzureDiskComplete":                  {alpha: 17, removed: 22},
+	"CSIMigrationAzureFile":                          {alpha: 15, beta: 21},
+	"CSIMigrationAzureFileComplete":                  {alpha: 17, removed: 22},
+	"CSIMigrationGCE":                                {alpha: 14, beta: 17, ga: 25},
+	"CSIMigrationGCEComplete":                        {alpha: 17, removed: 22},
+	"CSIMigrationOpenStack":                          {alpha: 14, beta: 18, ga: 24},
+	"CSIMigrationOpenStackComplete":                  {alpha: 17, removed: 22},
+	"CSIMigrationPortworx":                           {alpha: 23, beta: 25},
+	"CSIMigrationRBD":                                {alpha: 23},
+	"CSIMigrationvSphere":                            {alpha: 18, beta: 19},
+	"CSIMigrationvSphereComplete":                    {beta: 19, removed: 22},
+	"CSINodeExpandSecret":                            {alpha: 25},
+	"CSINodeInfo":                                    {alpha: 12, beta: 14, ga: 17, removed: 23},
+	"CSIServiceAccountToken":                         {alpha: 20, beta: 21, ga: 22, removed: 25},
+	"CSIStorageCapacity":                             {alpha: 19, beta: 21, ga: 24},
+	"CSIVolumeFSGroupPolicy":                         {alpha: 19, beta: 20, ga: 23},
+	"CSIVolumeHealth":                                {alpha: 21},
+	"CSRDuration":                                    {beta: 22, ga: 24},
+	"ConfigurableFSGroupPolicy":                      {alpha: 18, beta: 20, ga: 23},
+	"ContainerCheckpoint":                            {alpha: 25},
+	"ContextualLogging":                              {alpha: 24},
+	"ControllerManagerLeaderMigration":               {alpha: 21, beta: 22, ga: 24},
+	"CronJobControllerV2":                            {alpha: 20, beta: 21, ga: 22, removed: 24},
+	"CronJobTimeZone":                                {alpha: 24, beta: 25},
+	"CustomCPUCFSQuotaPeriod":                        {alpha: 12},
+	"CustomResourceValidationExpressions":            {alpha: 23, beta: 25},
+	"DaemonSetUpdateSurge":                           {alpha: 21, beta: 22, ga: 25},
+	"DefaultPodTopologySpread":                       {alpha: 19, beta: 20, ga: 24},
+	"DelegateFSGroupToCSIDriver":                     {alpha: 22, beta: 23},
+	"DevicePlugins":                                  {alpha: 8, beta: 10},
+	"DisableAcceleratorUsageMetrics":                 {alpha: 19, beta: 20, ga: 25},
+	"DisableCloudProviders":                          {alpha: 22},
+	"DisableKubeletCloudCredentialProviders":         {alpha: 23},
+	"DownwardAPIHugePages":                           {alpha: 20, beta: 21},
+	"DryRun":                                         {alpha: 12, beta: 13, ga: 19},
+	"DynamicKubeletConfig":                           {alpha: 4, beta: 11},
+	"EfficientWatchResumption":                       {alpha: 20, beta: 21, ga: 24},
+	"EndpointSlice":                                  {alpha: 16, beta: 17, ga: 21, removed: 25},
+	"EndpointSliceNodeName":                          {alpha: 20, ga: 21, removed: 25},
+	"EndpointSliceProxying":                          {alpha: 18, beta: 19, ga: 22, removed: 25},
+	"EndpointSliceTerminatingCondition":              {alpha: 20, beta: 22},
+	"EphemeralContainers":                            {alpha: 16, beta: 23, ga: 25},
+	"EvenPodsSpread":                                 {alpha: 16, beta: 18, ga: 19, removed: 21},
+	"ExecProbeTimeout":                               {ga: 20},
+	"ExpandCSIVolumes":                               {alpha: 14, beta: 16, ga: 24},
+	"ExpandInUsePersistentVolumes":                   {alpha: 11, beta: 15, ga: 24},
+	"ExpandPersistentVolumes":                        {alpha: 8, beta: 11, ga: 24},
+	"ExpandedDNSConfig":                              {alpha: 22},
+	"ExperimentalHostUserNamespaceDefaulting":        {beta: 5},
+	"ExternalPolicyForExternalIP":                    {ga: 18, removed: 22},
+	"GRPCContainerProbe":                             {alpha: 23, beta: 24},
+	"GenericEphemeralVolume":                         {alpha: 19, beta: 21, ga: 23, removed: 25},
+	"GracefulNodeShutdown":                           {alpha: 20, beta: 21},
+	"GracefulNodeShutdownBasedOnPodPriority":         {alpha: 23, beta: 24},
+	"HPAContainerMetrics":                            {alpha: 20},
+	"HPAScaleToZero":                                 {alpha: 16},
+	"HonorPVReclaimPolicy":                           {alpha: 23},
+	"HugePageStorageMediumSize":                      {alpha: 18, beta: 19, ga: 21, removed: 24},
+	"HugePages":                                      {alpha: 8, beta: 10, ga: 14, removed: 21},
+	"IPTablesOwnershipCleanup":                       {alpha: 25},
+	"IPv6DualStack":                                  {alpha: 15, beta: 21, ga: 23, removed: 25},
+	"IdentifyPodOS":                                  {alpha: 23, beta: 24, ga: 25},
+	"ImmutableEphemeralVolumes":                      {alpha: 18, beta: 19, ga: 21, removed: 24},
+	"InTreePluginAWSUnregister":                      {alpha: 21},
+	"InTreePluginAzureDiskUnregister":                {alpha: 21},
+	"InTreePluginAzureFileUnregister":                {alpha: 21},
+	"InTreePluginGCEUnregister":                      {alpha: 21},
+	"InTreePluginOpenStackUnregister":                {alpha: 21},
+	"InTreePluginPortworxUnregister":                 {alpha: 23},
+	"InTreePluginRBDUnregister":                      {alpha: 23},
+	"InTreePluginvSphereUnregister":                  {alpha: 21},
+	"IndexedJob":                                     {alpha: 21, beta: 22, ga: 24},
+	"IngressClassNamespacedParams":                   {alpha: 21, beta: 22, ga: 23, removed: 25},
+	"JobMutableNodeSchedulingDirectives":             {beta: 23},
+	"JobPodFailurePolicy":                            {alpha: 25},
+	"JobReadyPods":                                   {alpha: 23, beta: 24},
+	"JobTrackingWithFinalizers":                      {alpha: 22, beta: 23},
+	"KMSv2":                                          {alpha: 25},
+	"KubeletCredentialProviders":                     {alpha: 20, beta: 24},
+	"KubeletInUserNamespace":                         {alpha: 22},
+	"KubeletPodResources":                            {alpha: 13, beta: 15},
+	"KubeletPodResourcesGetAllocatable":              {alpha: 21, beta: 23},
+	"KubeletTracing":                                 {alpha: 25},
+	"LegacyNodeRoleBehavior":                         {alpha: 16, beta: 19, ga: 21, removed: 23},
+	"LegacyServiceAccountTokenNoAutoGeneration":      {beta: 24},
+	"LocalStorageCapacityIsolation":                  {alpha: 7, beta: 10, ga: 25},
+	"LocalStorageCapacityIsolationFSQuotaMonitoring": {alpha: 15},
+	"LogarithmicScaleDown":                           {alpha: 21, beta: 22},
+	"LoggingAlphaOptions":                            {alpha: 24},
+	"LoggingBetaOptions":                             {beta: 24},
+	"MatchLabelKeysInPodTopologySpread":              {alpha: 25},
+	"MaxUnavailableStatefulSet":                      {alpha: 24},
+	"MemoryManager":                                  {alpha: 21, beta: 22},
+	"MemoryQoS":                                      {alpha: 22},
+	"MinDomainsInPodTopologySpread":                  {alpha: 24, beta: 25},
+	"MixedProtocolLBService":                         {alpha: 20, beta: 24},
+	"MultiCIDRRangeAllocator":                        {alpha: 25},
+	"NamespaceDefaultLabelName":                      {beta: 21, ga: 22, removed: 24},
+	"NetworkPolicyEndPort":                           {alpha: 21, beta: 22, ga: 25},
+	"NetworkPolicyStatus":                            {alpha: 24},
+	"NodeDisruptionExclusion":                        {alpha: 16, beta: 19, ga: 21, removed: 23},
+	"NodeInclusionPolicyInPodTopologySpread":         {alpha: 25},
+	"NodeOutOfServiceVolumeDetach":                   {alpha: 24},
+	"NodeSwap":                                       {alpha: 22},
+	"NonPreemptingPriority":                          {alpha: 15, beta: 19, ga: 24},
+	"OpenAPIEnums":                                   {alpha: 23, beta: 24},
+	"OpenAPIV3":                                      {alpha: 23, beta: 24},
+	"PodAffinityNamespaceSelector":                   {alpha: 21, beta: 22, ga: 24},
+	"PodAndContainerStatsFromCRI":                    {alpha: 23},
+	"PodDeletionCost":                                {alpha: 21, beta: 22},
+	"PodDisruptionBudget":                            {alpha: 3, beta: 5, ga: 21, removed: 25},
+	"PodDisruptionConditions":                        {alpha: 25},
+	"PodHasNetworkCondition":                         {alpha: 25},
+	"PodOverhead":                                    {alpha: 16, beta: 18, ga: 24},
+	"PodSecurity":                                    {alpha: 22, beta: 23, ga: 25},
+	"PreferNominatedNode":                            {alpha: 21, beta: 22, ga: 24},
+	"ProbeTerminationGracePeriod":                    {alpha: 21, beta: 22},
+	"ProcMountType":                                  {alpha: 12},
+	"ProxyTerminatingEndpoints":                      {alpha: 22},
+	"QOSReserved":                                    {alpha: 11},
+	"ReadWriteOncePod":                               {alpha: 22},
+	"RecoverVolumeExpansionFailure":                  {alpha: 23},
+	"RemainingItemCount":                             {alpha: 15, beta: 16},
+	"RemoveSelfLink":                                 {alpha: 16, beta: 20, ga: 24},
+	"RetroactiveDefaultStorageClass":                 {alpha: 25},
+	"RootCAConfigMap":                                {alpha: 13, beta: 20, ga: 21, removed: 23},
+	"RotateKubeletServerCertificate":                 {alpha: 7, beta: 12},
+	"RunAsGroup":                                     {alpha: 10, beta: 14, ga: 21, removed: 23},
+	"RuntimeClass":                                   {alpha: 12, beta: 14, ga: 20, removed: 25},
+	"SCTPSupport":                                    {alpha: 12, beta: 19, ga: 20, removed: 23},
+	"SeccompDefault":                                 {alpha: 22, beta: 25},
+	"SelectorIndex":                                  {alpha: 18, beta: 19, ga: 20, removed: 25},
+	"ServerSideApply":                                {alpha: 14, beta: 16, ga: 22},
+	"ServerSideFieldValidation":                      {alpha: 23, beta: 25},
+	"ServiceAccountIssuerDiscovery":                  {alpha: 18, beta: 20, ga: 21, removed: 24},
+	"ServiceAppProtocol":                             {alpha: 18, beta: 19, ga: 20, removed: 23},
+	"ServiceIPStaticSubrange":                        {alpha: 24, beta: 25},
+	"ServiceInternalTrafficPolicy":                   {alpha: 21, beta: 22},
+	"ServiceLBNodePortControl":                       {alpha: 20, beta: 22, ga: 24},
+	"ServiceLoadBalancerClass":                       {alpha: 21, beta: 22, ga: 24},
+	"ServiceNodeExclusion":                           {alpha: 8, beta: 19, ga: 21, removed: 23},
+	"ServiceTopology":                                {alpha: 17, removed: 22},
+	"SetHostnameAsFQDN":                              {alpha: 19, beta: 20, ga: 22, removed: 24},
+	"SizeMemoryBackedVolumes":                        {alpha: 20, beta: 22},
+	"StartupProbe":                                   {alpha: 16, beta: 18, ga: 20, removed: 23},
+	"StatefulSetAutoDeletePVC":                       {alpha: 22},
+	"StatefulSetMinReadySeconds":                     {alpha: 22, beta: 23, ga: 25},
+	"StorageObjectInUseProtection":                   {beta: 10, ga: 11, removed: 25},
+	"StorageVersionAPI":                              {alpha: 20},
+	"StorageVersionHash":                             {alpha: 14, beta: 15},
+	"StreamingProxyRedirects":                        {beta: 5, removed: 24},
+	"SupportNodePidsLimit":                           {alpha: 14, beta: 15, ga: 20, removed: 24},
+	"SupportPodPidsLimit":                            {alpha: 10, beta: 14, ga: 20, removed: 24},
+	"SuspendJob":                                     {alpha: 21, beta: 22, ga: 24},
+	"Sysctls":                                        {beta: 11, ga: 21, removed: 23},
+	"TTLAfterFinished":                               {alpha: 12, beta: 21, ga: 23, removed: 25},
+	"TokenRequest":                                   {alpha: 10, beta: 12, ga: 20, removed: 21},
+	"TokenRequestProjection":                         {alpha: 11, beta: 12, ga: 20, removed: 21},
+	"TopologyAwareHints":                             {alpha: 21, beta: 23},
+	"TopologyManager":                                {alpha: 16, beta: 18},
+	"UserNamespacesStatelessPodsSupport":             {alpha: 25},
+	"ValidateProxyRedirects":                         {alpha: 12, beta: 14, removed: 24},
+	"VolumeCapacityPriority":                         {alpha: 21},
+	"VolumeSnapshotDataSource":                       {alpha: 12, beta: 17, ga: 20, removed: 22},
+	"VolumeSubpath":                                  {ga: 10, removed: 25},
+	"WarningHeaders":                                 {beta: 19, ga: 22, removed: 24},
+	"WatchBookmark":                                  {alpha: 15, beta: 16, ga: 17},
+	"WinDSR":                                         {alpha: 14},
+	"WinOverlay":                                     {alpha: 14, beta: 20},
+	"WindowsEndpointSliceProxying":                   {alpha: 19, beta: 21, ga: 22, removed: 25},
+	"WindowsHostProcessContainers":                   {alpha: 22, beta: 23},
+}
+
+// stage returns the stage of the gate in the given minor version, false if the gate does not exist in that version
+func (g gate) stage(minor int) (Stage, bool) {
+	if g.removed != 0 && minor >= g.removed {
+		return "", false
+	}
+	switch {
+	case g.ga != 0 && minor >= g.ga:
+		return GA, true
+	case g.beta != 0 && minor >= g.beta:
+		return Beta, true
+	case g.alpha != 0 && minor >= g.alpha:
+		return Alpha, true
+	}
+	return "", false
+}
+
+// minorVersion returns the minor version of the given Kubernetes 1.x version, 0 if unknown
+func minorVersion(kubeVersion string) int {
+	v, err := version.ParseGeneric(kubeVersion)
+	if err != nil || v.Major() != 1 {
+		return 0
+	}
+	return int(v.Minor())
+}
+
+// Validate checks the feature gate exists in the given Kubernetes version.
+// Gates are only checked by name for versions this package does not cover, or when the version is unknown.
+func Validate(kubeVersion string, name string) error {
+	g, known := gates[name]
+	minor := minorVersion(kubeVersion)
+
+	if minor < minMinor || minor > maxMinor {
+		// the gates of newer versions are not known, the ones removed before the oldest covered version neither
+		if !known && minor == 0 {
+			return errors.Errorf("unknown feature gate %s", name)
+		}
+		if known && g.removed != 0 && minor >= g.removed {
+			return errors.Errorf("feature gate %s has been removed in Kubernetes v1.%d", name, g.removed)
+		}
+		return nil
+	}
+
+	if !known {
+		return errors.Errorf("unknown feature gate %s in Kubernetes %s", name, kubeVersion)
+	}
+	if _, ok := g.stage(minor); !ok {
+		if g.removed != 0 && minor >= g.removed {
+			return errors.Errorf("feature gate %s has been removed in Kubernetes v1.%d", name, g.removed)
+		}
+		return errors.Errorf("feature gate %s is not available in Kubernetes %s", name, kubeVersion)
+	}
+	return nil
+}
+
+// ValidateRuntimeConfig checks a runtime config entry, e.g. api/alpha=true or batch/v2alpha1=true
+func ValidateRuntimeConfig(key string, value string) error {
+	segments := strings.Split(key, "/")
+	if len(segments) < 2 || len(segments) > 3 {
+		return errors.Errorf("invalid runtime config key %s, must be api/<all|ga|beta|alpha> or <group>/<version>[/<resource>]", key)
+	}
+	for _, s := range segments {
+		if s == "" {
+			return errors.Errorf("invalid runtime config key %s", key)
+		}
+	}
+	if _, err := strconv.ParseBool(value); err != nil {
+		return errors.Errorf("invalid runtime config value %q for %s, must be true or false", value, key)
+	}
+	return nil
+}
+
+// AlphaFeatures returns the enabled alpha feature gates, for the given Kubernetes version, and the runtime config entries
+// enabling alpha APIs, sorted
+func AlphaFeatures(kubeVersion string, featureGates map[string]bool, runtimeConfig map[string]string) []string {
+	minor := minorVersion(kubeVersion)
+
+	var alpha []string
+	for name, enabled := range featureGates {
+		if !enabled {
+			continue
+		}
+		g, known := gates[name]
+		if !known {
+			// not known by this package, most likely a new alpha gate
+			alpha = append(alpha, name)
+			continue
+		}
+		if minor == 0 {
+			minor = maxMinor
+		}
+		if stage, ok := g.stage(minor); ok && stage == Alpha {
+			alpha = append(alpha, name)
+		}
+	}
+	for key, value := range runtimeConfig {
+		if enabled, _ := strconv.ParseBool(value); !enabled {
+			continue
+		}
+		segments := strings.Split(key, "/")
+		if key == "api/alpha" || key == "api/all" || (len(segments) >= 2 && strings.Contains(segments[1], "alpha")) {
+			alpha = append(alpha, key)
+		}
+	}
+	sort.Strings(alpha)
+	return alpha
+}
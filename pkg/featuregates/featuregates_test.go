@@ -0,0 +1,135 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package featuregates
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	_ "k8s.io/apiserver/pkg/features"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+	"k8s.io/component-base/featuregate"
+	logsapi "k8s.io/component-base/logs/api/v1"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		kubeVersion string
+		gate        string
+		wantErr     string
+	}{
+		{name: "alpha gate", kubeVersion: "v1.25.3", gate: "APIServerTracing"},
+		{name: "GA gate", kubeVersion: "v1.25.3", gate: "CSIMigration"},
+		{name: "all alpha gates", kubeVersion: "v1.25.3", gate: "AllAlpha"},
+		{name: "version without v", kubeVersion: "1.22.15", gate: "APIServerTracing"},
+		{name: "unknown gate", kubeVersion: "v1.25.3", gate: "NoSuchGate", wantErr: "unknown feature gate NoSuchGate in Kubernetes v1.25.3"},
+		{name: "removed gate", kubeVersion: "v1.24.7", gate: "WarningHeaders", wantErr: "feature gate WarningHeaders has been removed in Kubernetes v1.24"},
+		{name: "gate of a newer version", kubeVersion: "v1.24.7", gate: "JobPodFailurePolicy", wantErr: "feature gate JobPodFailurePolicy is not available in Kubernetes v1.24.7"},
+		{name: "removed gate of an older version", kubeVersion: "v1.20.15", gate: "CSIBlockVolume"},
+		{name: "unknown gate of an uncovered version", kubeVersion: "v1.27.3", gate: "NoSuchGate"},
+		{name: "removed gate of an uncovered version", kubeVersion: "v1.27.3", gate: "WarningHeaders", wantErr: "feature gate WarningHeaders has been removed in Kubernetes v1.24"},
+		{name: "known gate of an unknown version", kubeVersion: "", gate: "APIServerTracing"},
+		{name: "unknown gate of an unknown version", kubeVersion: "latest", gate: "NoSuchGate", wantErr: "unknown feature gate NoSuchGate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			err := Validate(tt.kubeVersion, tt.gate)
+			if tt.wantErr != "" {
+				g.Expect(err).To(MatchError(tt.wantErr))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestValidateRuntimeConfig(t *testing.T) {
+	tests := []struct {
+		key, value string
+		wantErr    bool
+	}{
+		{key: "api/alpha", value: "true"},
+		{key: "batch/v2alpha1", value: "false"},
+		{key: "storage.k8s.io/v1alpha1/csistoragecapacities", value: "true"},
+		{key: "alpha", value: "true", wantErr: true},
+		{key: "batch//jobs", value: "true", wantErr: true},
+		{key: "a/b/c/d", value: "true", wantErr: true},
+		{key: "api/alpha", value: "yes", wantErr: true},
+	}
+	for _, tt := range tests {
+		g := NewWithT(t)
+		err := ValidateRuntimeConfig(tt.key, tt.value)
+		g.Expect(err != nil).To(Equal(tt.wantErr), "%s=%s: %v", tt.key, tt.value, err)
+	}
+}
+
+func TestAlphaFeatures(t *testing.T) {
+	g := NewWithT(t)
+	featureGates := map[string]bool{
+		"APIServerTracing":        true,
+		"CPUManagerPolicyOptions": true,
+		"CSIMigration":            true,
+		"KMSv2":                   false,
+		"NoSuchGate":              true,
+	}
+	runtimeConfig := map[string]string{
+		"api/alpha":               "true",
+		"api/beta":                "true",
+		"batch/v2alpha1":          "false",
+		"storage.k8s.io/v1alpha1": "true",
+	}
+
+	// CPUManagerPolicyOptions is alpha in 1.22 and beta from 1.23
+	g.Expect(AlphaFeatures("v1.22.15", featureGates, runtimeConfig)).To(Equal([]string{
+		"APIServerTracing", "CPUManagerPolicyOptions", "NoSuchGate", "api/alpha", "storage.k8s.io/v1alpha1",
+	}))
+	g.Expect(AlphaFeatures("v1.25.3", featureGates, runtimeConfig)).To(Equal([]string{
+		"APIServerTracing", "NoSuchGate", "api/alpha", "storage.k8s.io/v1alpha1",
+	}))
+	g.Expect(AlphaFeatures("v1.25.3", map[string]bool{"AllAlpha": true, "AllBeta": true}, nil)).To(Equal([]string{"AllAlpha"}))
+	g.Expect(AlphaFeatures("v1.25.3", nil, nil)).To(BeEmpty())
+}
+
+// the gates Kubernetes 1.25 defines in the libraries the provider depends on must be in the table, with their stage
+func TestGatesOfLibraries(t *testing.T) {
+	g := NewWithT(t)
+	stages := map[featuregate.Feature]featuregate.FeatureSpec{}
+	for name, spec := range utilfeature.DefaultMutableFeatureGate.GetAll() {
+		stages[name] = spec
+	}
+	logging := featuregate.NewFeatureGate()
+	g.Expect(logsapi.AddFeatureGates(logging)).To(Succeed())
+	for name, spec := range logging.GetAll() {
+		stages[name] = spec
+	}
+	g.Expect(stages).To(HaveKey(featuregate.Feature("KMSv2")))
+
+	for name, spec := range stages {
+		stage, ok := gates[string(name)].stage(25)
+		g.Expect(ok).To(BeTrue(), "feature gate %s of Kubernetes 1.25 missing", name)
+		switch spec.PreRelease {
+		case featuregate.Alpha:
+			g.Expect(stage).To(Equal(Alpha), "stage of feature gate %s in Kubernetes 1.25", name)
+		case featuregate.Beta:
+			g.Expect(stage).To(Equal(Beta), "stage of feature gate %s in Kubernetes 1.25", name)
+		case featuregate.GA:
+			g.Expect(stage).To(Equal(GA), "stage of feature gate %s in Kubernetes 1.25", name)
+		}
+	}
+}
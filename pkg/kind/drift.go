@@ -0,0 +1,240 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+	"sigs.k8s.io/kind/pkg/exec"
+)
+
+// NodeDrift is the difference between the node containers of a kind cluster and the ones kind created for it
+// or added by scaling.
+type NodeDrift struct {
+	// Node containers that are not running
+	Stopped []string
+	// Control plane and load balancer containers that have been removed, the cluster cannot be repaired without them
+	MissingControlPlane []string
+	// Worker containers of the node pools that have been removed
+	MissingWorkers []string
+	// Worker containers of spec.workerCount that have been removed, see CreatedWorkerCount
+	MissingScaledWorkers []string
+}
+
+// Drifted reports whether some node containers are stopped or missing
+func (d *NodeDrift) Drifted() bool {
+	return len(d.Stopped) > 0 || len(d.MissingControlPlane) > 0 || len(d.MissingWorkers) > 0 || len(d.MissingScaledWorkers) > 0
+}
+
+// String summarizes the drift for conditions and events
+func (d *NodeDrift) String() string {
+	var parts []string
+	if len(d.MissingControlPlane) > 0 {
+		parts = append(parts, "missing control plane nodes: "+strings.Join(d.MissingControlPlane, ", "))
+	}
+	if missing := append(append([]string{}, d.MissingWorkers...), d.MissingScaledWorkers...); len(missing) > 0 {
+		parts = append(parts, "missing worker nodes: "+strings.Join(missing, ", "))
+	}
+	if len(d.Stopped) > 0 {
+		parts = append(parts, "stopped nodes: "+strings.Join(d.Stopped, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// NodeDrift compares the node containers of the kind cluster, and whether they are running, with the nodes
+// created by kind or by scaling, see CreatedControlPlaneCount and CreatedWorkerCount. Node containers of KindMachines
+// are not considered.
+func (k *KindLibHelper) NodeDrift(ctx context.Context, kindCluster *v1beta1.KindCluster) (*NodeDrift, error) {
+	clusterName := ClusterName(kindCluster)
+	allNodes, err := k.listNodes(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	machines, err := k.machineNodes(ctx, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	existing := make([]string, 0, len(allNodes))
+	for _, n := range allNodes {
+		if !machines[n.String()] {
+			existing = append(existing, n.String())
+		}
+	}
+	running, err := k.runningContainers(ctx, existing)
+	if err != nil {
+		return nil, err
+	}
+	return nodeDrift(kindCluster, existing, running), nil
+}
+
+// nodeDrift compares the existing node containers of the kind cluster with the nodes kind created
+func nodeDrift(kindCluster *v1beta1.KindCluster, existing []string, running map[string]bool) *NodeDrift {
+	clusterName := ClusterName(kindCluster)
+	exists := map[string]bool{}
+	for _, name := range existing {
+		exists[name] = true
+	}
+
+	drift := &NodeDrift{}
+	controlPlanes := int(CreatedControlPlaneCount(kindCluster))
+	for i := 1; i <= controlPlanes; i++ {
+		if name := nodeName(clusterName, constants.ControlPlaneNodeRoleValue, i); !exists[name] {
+			drift.MissingControlPlane = append(drift.MissingControlPlane, name)
+		}
+	}
+	if controlPlanes > 1 {
+		if name := clusterName + "-" + constants.ExternalLoadBalancerNodeRoleValue; !exists[name] {
+			drift.MissingControlPlane = append(drift.MissingControlPlane, name)
+		}
+	}
+	poolWorkers := poolWorkerCount(kindCluster)
+	for i := 1; i <= poolWorkers; i++ {
+		if name := workerName(clusterName, i); !exists[name] {
+			drift.MissingWorkers = append(drift.MissingWorkers, name)
+		}
+	}
+	for i := poolWorkers + 1; i <= poolWorkers+int(CreatedWorkerCount(kindCluster)); i++ {
+		if name := workerName(clusterName, i); !exists[name] {
+			drift.MissingScaledWorkers = append(drift.MissingScaledWorkers, name)
+		}
+	}
+
+	for _, name := range existing {
+		if running[name] || !kindNode(clusterName, name) {
+			continue
+		}
+		drift.Stopped = append(drift.Stopped, name)
+	}
+	return drift
+}
+
+// kindNode reports whether the node container has been created by kind or by scaling. The nodes of KindMachines are
+// excluded by their label, the ones created before they were labelled are not named as the nodes of kind.
+func kindNode(clusterName string, name string) bool {
+	return name == clusterName+"-"+constants.ExternalLoadBalancerNodeRoleValue ||
+		strings.HasPrefix(name, clusterName+"-"+constants.ControlPlaneNodeRoleValue) ||
+		workerIndex(clusterName, name) > 0
+}
+
+// runningContainers returns which of the given containers are running
+func (k *KindLibHelper) runningContainers(ctx context.Context, names []string) (map[string]bool, error) {
+	running := map[string]bool{}
+	if len(names) == 0 {
+		return running, nil
+	}
+	args := append([]string{"inspect", "--format", "{{.Name}} {{.State.Running}}"}, names...)
+	lines, err := exec.OutputLines(exec.CommandContext(ctx, k.runtime, args...))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to inspect node containers")
+	}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		// docker prefixes container names with a slash
+		running[strings.TrimPrefix(fields[0], "/")] = fields[1] == "true"
+	}
+	return running, nil
+}
+
+// StartNodes starts the given stopped node containers of the kind cluster
+func (k *KindLibHelper) StartNodes(ctx context.Context, kindCluster *v1beta1.KindCluster, names []string) error {
+	logger := log.FromContext(ctx)
+	for _, name := range names {
+		logger.Info("Starting node container", "cluster", ClusterName(kindCluster), "node", name)
+		if err := exec.CommandContext(ctx, k.runtime, "start", name).Run(); err != nil {
+			return errors.Wrapf(err, "failed to start node container %s", name)
+		}
+	}
+	return nil
+}
+
+// RecreateWorker creates the removed worker node of a node pool, with the configuration of its pool, or of
+// spec.workerCount again, replacing the Kubernetes node left behind
+func (k *KindLibHelper) RecreateWorker(ctx context.Context, kindCluster *v1beta1.KindCluster, name string) error {
+	clusterName := ClusterName(kindCluster)
+	index := workerIndex(clusterName, name)
+	if index <= 0 {
+		return classify(ErrInvalidConfig, errors.Errorf("node %s is not a worker of the kind cluster", name))
+	}
+	pool := workerPool(kindCluster, index)
+
+	allNodes, err := k.listNodes(clusterName)
+	if err != nil {
+		return err
+	}
+	controlPlane, err := bootstrapControlPlaneNode(clusterName, allNodes)
+	if err != nil {
+		return err
+	}
+	if err := kubectl(ctx, controlPlane, "delete", "node", name, "--ignore-not-found").Run(); err != nil {
+		return errors.Wrapf(err, "failed to delete node %s", name)
+	}
+
+	image := kindCluster.Status.Image
+	if pool != nil && pool.Image != "" {
+		image = pool.Image
+	}
+	if image == "" {
+		if image, _, err = k.inspectNode(ctx, controlPlane.String()); err != nil {
+			return err
+		}
+	}
+	return k.addWorker(ctx, kindCluster, controlPlane, name, image, pool, nil)
+}
+
+// ControlPlaneCount returns the number of control plane nodes of the spec of the kind cluster, node pools included
+func ControlPlaneCount(kindCluster *v1beta1.KindCluster) int32 {
+	count := kindCluster.Spec.ControlPlaneCount
+	for _, pool := range kindCluster.Spec.NodePools {
+		if pool.Role == v1beta1.ControlPlaneRole {
+			count += pool.Replicas
+		}
+	}
+	return count
+}
+
+// CreatedControlPlaneCount returns the number of control plane nodes kind created the cluster with, recorded in
+// status.controlPlaneNodes: the control plane is never scaled. Clusters created before it was recorded have the
+// control plane of their spec.
+func CreatedControlPlaneCount(kindCluster *v1beta1.KindCluster) int32 {
+	if kindCluster.Status.ControlPlaneNodes > 0 {
+		return kindCluster.Status.ControlPlaneNodes
+	}
+	return ControlPlaneCount(kindCluster)
+}
+
+// CreatedWorkerCount returns the number of worker nodes of spec.workerCount the kind cluster was created or scaled
+// to, recorded in status.workerNodes. Clusters created before it was recorded have none until they are scaled.
+func CreatedWorkerCount(kindCluster *v1beta1.KindCluster) int32 {
+	return kindCluster.Status.WorkerNodes
+}
+
+// nodeName returns the name kind gives to the node with the given role and index (<cluster>-<role>, <cluster>-<role>2, ...)
+func nodeName(clusterName string, role string, index int) string {
+	if index <= 1 {
+		return fmt.Sprintf("%s-%s", clusterName, role)
+	}
+	return fmt.Sprintf("%s-%s%d", clusterName, role, index)
+}
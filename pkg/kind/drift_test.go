@@ -0,0 +1,122 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"testing"
+
+	"github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	. "github.com/onsi/gomega"
+)
+
+func TestNodeDrift(t *testing.T) {
+	kindCluster := &v1beta1.KindCluster{Spec: v1beta1.KindClusterSpec{
+		KindClusterName:   "dev",
+		ControlPlaneCount: 1,
+		WorkerCount:       1,
+		NodePools: []v1beta1.NodePool{
+			{Name: "infra", Role: v1beta1.WorkerRole, Replicas: 1},
+			{Name: "etcd", Role: v1beta1.ControlPlaneRole, Replicas: 2},
+		},
+	}}
+	all := []string{
+		"dev-control-plane", "dev-control-plane2", "dev-control-plane3", "dev-external-load-balancer",
+		"dev-worker", "dev-worker2", "dev-machine-0",
+	}
+	running := func(names ...string) map[string]bool {
+		m := map[string]bool{}
+		for _, name := range names {
+			m[name] = true
+		}
+		return m
+	}
+
+	tests := []struct {
+		name              string
+		controlPlaneCount int32
+		uncounted         bool
+		existing          []string
+		running           map[string]bool
+		want              NodeDrift
+	}{
+		{
+			name:     "healthy",
+			existing: all,
+			running:  running(all...),
+		},
+		{
+			name:     "stopped nodes",
+			existing: all,
+			running:  running("dev-control-plane2", "dev-control-plane3", "dev-external-load-balancer", "dev-worker"),
+			want:     NodeDrift{Stopped: []string{"dev-control-plane", "dev-worker2"}},
+		},
+		{
+			name:     "stopped machine",
+			existing: all,
+			running:  running(all[:6]...),
+		},
+		{
+			name:     "missing control plane and load balancer",
+			existing: []string{"dev-control-plane", "dev-control-plane3", "dev-worker", "dev-worker2"},
+			running:  running(all...),
+			want:     NodeDrift{MissingControlPlane: []string{"dev-control-plane2", "dev-external-load-balancer"}},
+		},
+		{
+			name:     "missing pool worker",
+			existing: []string{"dev-control-plane", "dev-control-plane2", "dev-control-plane3", "dev-external-load-balancer", "dev-worker2"},
+			running:  running(all...),
+			want:     NodeDrift{MissingWorkers: []string{"dev-worker"}},
+		},
+		{
+			name:     "missing worker",
+			existing: all[:5],
+			running:  running(all...),
+			want:     NodeDrift{MissingScaledWorkers: []string{"dev-worker2"}},
+		},
+		{
+			// created before the workers were counted
+			name:      "missing worker of a legacy cluster",
+			uncounted: true,
+			existing:  all[:5],
+			running:   running(all...),
+		},
+		{
+			// the control plane is never scaled, kind created 3 control plane nodes
+			name:              "changed control plane count",
+			controlPlaneCount: 3,
+			existing:          all,
+			running:           running(all...),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			kindCluster := kindCluster.DeepCopy()
+			kindCluster.Status.ControlPlaneNodes = 3
+			if !tt.uncounted {
+				kindCluster.Status.WorkerNodes = 1
+			}
+			if tt.controlPlaneCount > 0 {
+				kindCluster.Spec.ControlPlaneCount = tt.controlPlaneCount
+			}
+			drift := nodeDrift(kindCluster, tt.existing, tt.running)
+			g.Expect(*drift).To(Equal(tt.want))
+			g.Expect(drift.Drifted()).To(Equal(tt.want.Drifted()))
+		})
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kind/pkg/cluster/nodes"
+	"sigs.k8s.io/kind/pkg/cluster/nodeutils"
+)
+
+// Errors of the kind helper the reconcilers act upon, matched with errors.Is. The error of the failing call is
+// wrapped, see kindError.
+var (
+	// ErrRuntimeUnavailable is returned when the container runtime cannot be reached, e.g. the docker daemon is down.
+	// Transient: nothing can be known about the kind cluster, it must not be taken as missing.
+	ErrRuntimeUnavailable = errors.New("container runtime unavailable")
+	// ErrClusterNotFound is returned when the kind cluster has no node containers. Terminal for the operation,
+	// the kind cluster has to be created again.
+	ErrClusterNotFound = errors.New("kind cluster not found")
+	// ErrEndpointUnavailable is returned when the API server address of the kind cluster cannot be read from its
+	// kubeconfig. Transient: the control plane may still be starting.
+	ErrEndpointUnavailable = errors.New("kind cluster endpoint unavailable")
+	// ErrInvalidConfig is returned when the spec of the KindCluster cannot be applied, e.g. malformed kubeadm
+	// config patches. Terminal until the spec is changed.
+	ErrInvalidConfig = errors.New("invalid kind cluster configuration")
+)
+
+// kindError is an error of the kind helper classified by one of the errors above
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string { return e.kind.Error() + ": " + e.err.Error() }
+
+func (e *kindError) Unwrap() error { return e.err }
+
+func (e *kindError) Is(target error) bool { return target == e.kind }
+
+// classify wraps err, if any, with the given kind of error
+func classify(kind error, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+// IsTransient reports whether the error is expected to go away by itself, the operation is retried later as is
+func IsTransient(err error) bool {
+	return errors.Is(err, ErrRuntimeUnavailable) || errors.Is(err, ErrEndpointUnavailable)
+}
+
+// IsTerminal reports whether retrying the operation cannot succeed until the KindCluster or the kind cluster changes
+func IsTerminal(err error) bool {
+	return errors.Is(err, ErrClusterNotFound) || errors.Is(err, ErrInvalidConfig)
+}
+
+// listNodes returns the node containers of the kind cluster, a failure to list them means the runtime is unavailable
+func (k *KindLibHelper) listNodes(clusterName string) ([]nodes.Node, error) {
+	allNodes, err := k.Provider.ListNodes(clusterName)
+	if err != nil {
+		return nil, classify(ErrRuntimeUnavailable, err)
+	}
+	return allNodes, nil
+}
+
+// bootstrapControlPlaneNode returns the first control plane node of the kind cluster, see nodeutils
+func bootstrapControlPlaneNode(clusterName string, allNodes []nodes.Node) (nodes.Node, error) {
+	if len(allNodes) == 0 {
+		return nil, classify(ErrClusterNotFound, errors.Errorf("no node containers for kind cluster %s", clusterName))
+	}
+	return nodeutils.BootstrapControlPlaneNode(allNodes)
+}
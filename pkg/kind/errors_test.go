@@ -0,0 +1,68 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+)
+
+func TestErrors(t *testing.T) {
+	cause := errors.New("Cannot connect to the Docker daemon")
+
+	tests := []struct {
+		name          string
+		err           error
+		wantTransient bool
+		wantTerminal  bool
+	}{
+		{
+			name:          "runtime unavailable",
+			err:           classify(ErrRuntimeUnavailable, cause),
+			wantTransient: true,
+		},
+		{
+			name:          "endpoint of a missing cluster",
+			err:           classify(ErrEndpointUnavailable, classify(ErrClusterNotFound, cause)),
+			wantTransient: true,
+			wantTerminal:  true,
+		},
+		{
+			name:         "wrapped invalid config",
+			err:          errors.Wrap(classify(ErrInvalidConfig, cause), "failed to add worker"),
+			wantTerminal: true,
+		},
+		{
+			name: "unclassified",
+			err:  cause,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			g.Expect(IsTransient(tt.err)).To(Equal(tt.wantTransient))
+			g.Expect(IsTerminal(tt.err)).To(Equal(tt.wantTerminal))
+			g.Expect(errors.Is(tt.err, cause)).To(BeTrue())
+			g.Expect(tt.err.Error()).To(HaveSuffix(cause.Error()))
+		})
+	}
+	g := NewWithT(t)
+	g.Expect(classify(ErrRuntimeUnavailable, nil)).To(BeNil())
+}
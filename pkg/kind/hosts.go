@@ -0,0 +1,322 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	v1alpha4Kind "sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+	"sigs.k8s.io/kind/pkg/exec"
+)
+
+// Host is a remote docker daemon kind clusters are created on, see v1beta1.KindHost.
+// The docker CLI selects its daemon with the environment: each host has its own docker CLI, a wrapper setting
+// DOCKER_HOST and the related variables, see AcquireHost.
+type Host struct {
+	// Name identifies the host, <namespace>/<name> of its KindHost
+	Name string
+	// Endpoint of the docker daemon, as in DOCKER_HOST
+	Endpoint string
+	// Address the API servers of the kind clusters are reached at
+	Address string
+
+	caCert, clientCert, clientKey []byte
+	sshKey, knownHosts            []byte
+}
+
+// NewHost returns the Host of a KindHost, with the endpoint and the credentials of its Secret
+func NewHost(kindHost *v1beta1.KindHost, secret *corev1.Secret) (*Host, error) {
+	host := &Host{
+		Name:       kindHost.Namespace + "/" + kindHost.Name,
+		Endpoint:   string(secret.Data[v1beta1.KindHostEndpointKey]),
+		Address:    kindHost.Spec.Address,
+		caCert:     secret.Data[v1beta1.KindHostCACertKey],
+		clientCert: secret.Data[v1beta1.KindHostClientCertKey],
+		clientKey:  secret.Data[v1beta1.KindHostClientKeyKey],
+		sshKey:     secret.Data[v1beta1.KindHostSSHPrivateKeyKey],
+		knownHosts: secret.Data[v1beta1.KindHostKnownHostsKey],
+	}
+
+	endpoint, err := url.Parse(host.Endpoint)
+	if err != nil || endpoint.Hostname() == "" {
+		return nil, errors.Errorf("invalid %s %q in Secret %s, tcp://<host>:<port> or ssh://[<user>@]<host>[:<port>] expected",
+			v1beta1.KindHostEndpointKey, host.Endpoint, secret.Name)
+	}
+	switch endpoint.Scheme {
+	case "tcp":
+		certs := 0
+		for _, cert := range [][]byte{host.caCert, host.clientCert, host.clientKey} {
+			if len(cert) > 0 {
+				certs++
+			}
+		}
+		if certs != 0 && certs != 3 {
+			return nil, errors.Errorf("%s, %s and %s must be set together in Secret %s",
+				v1beta1.KindHostCACertKey, v1beta1.KindHostClientCertKey, v1beta1.KindHostClientKeyKey, secret.Name)
+		}
+	case "ssh":
+		if len(host.sshKey) == 0 {
+			return nil, errors.Errorf("%s is required by ssh endpoints, missing in Secret %s", v1beta1.KindHostSSHPrivateKeyKey, secret.Name)
+		}
+	default:
+		return nil, errors.Errorf("unsupported scheme %q of %s in Secret %s, tcp or ssh expected", endpoint.Scheme, v1beta1.KindHostEndpointKey, secret.Name)
+	}
+
+	if host.Address == "" {
+		host.Address = endpoint.Hostname()
+	}
+	return host, nil
+}
+
+// hostsDir is the directory the files of the hosts in use are written to, see AcquireHost
+var hostsDir = filepath.Join(os.TempDir(), "kind-hosts")
+
+// dir returns the private directory the docker CLI of the host and its credentials are written to
+func (h *Host) dir() string {
+	return filepath.Join(hostsDir, filepath.FromSlash(h.Name))
+}
+
+// cli returns the CLI of the container runtime on the host: the runtime itself on the container host of the
+// controller (nil), the docker CLI of the host otherwise, see AcquireHost
+func (h *Host) cli(runtime v1beta1.ContainerRuntime) string {
+	if h == nil {
+		return string(runtime)
+	}
+	return filepath.Join(h.dir(), "docker")
+}
+
+// configure publishes the API server of the kind cluster on all the addresses of the host, unless an address is set,
+// with a certificate valid for the address of the host
+func (h *Host) configure(cfg *v1alpha4Kind.Cluster) {
+	if cfg.Networking.APIServerAddress == "" {
+		cfg.Networking.APIServerAddress = "0.0.0.0"
+	}
+	// kind only applies the patch of the kubeadm API version it generates for the node image
+	patch := fmt.Sprintf(`[{"op": "add", "path": "/apiServer/certSANs/-", "value": %q}]`, h.Address)
+	for _, version := range []string{"v1beta2", "v1beta3"} {
+		cfg.KubeadmConfigPatchesJSON6902 = append(cfg.KubeadmConfigPatchesJSON6902, v1alpha4Kind.PatchJSON6902{
+			Group: "kubeadm.k8s.io", Version: version, Kind: "ClusterConfiguration", Patch: patch,
+		})
+	}
+}
+
+// server rewrites the API server URL of a kubeconfig generated by kind when it listens on all the addresses
+// of the host, see configure
+func (h *Host) server(kubeconfig string) string {
+	return strings.Replace(kubeconfig, "https://0.0.0.0:", "https://"+urlHost(h.Address)+":", 1)
+}
+
+// urlHost brackets IPv6 addresses to be used as the host of an URL
+func urlHost(address string) string {
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		return "[" + address + "]"
+	}
+	return address
+}
+
+// unsetEnvVars are the environment variables selecting a docker daemon that the docker CLI of a host does not inherit
+var unsetEnvVars = []string{"DOCKER_CONTEXT", "DOCKER_TLS_VERIFY", "DOCKER_CERT_PATH"}
+
+// environment returns the environment variables the docker CLI of the host runs with, selecting its daemon
+// with the credentials of its directory. path is the PATH to find the docker and ssh CLIs in.
+func (h *Host) environment(path string) map[string]string {
+	dir := h.dir()
+	env := map[string]string{"DOCKER_HOST": h.Endpoint}
+	if len(h.caCert) > 0 {
+		env["DOCKER_TLS_VERIFY"] = "1"
+		env["DOCKER_CERT_PATH"] = dir
+	}
+	if len(h.sshKey) > 0 {
+		// the docker CLI runs "ssh" from the PATH, wrapped to use the key and the known hosts of the host
+		env["PATH"] = filepath.Join(dir, "bin") + string(os.PathListSeparator) + path
+	}
+	return env
+}
+
+// files returns the files of the directory of the host by path relative to it: its credentials, the ssh
+// wrapper of ssh endpoints and the docker CLI, a wrapper running docker with the environment of the host
+func (h *Host) files(path string) map[string][]byte {
+	dir := h.dir()
+	files := map[string][]byte{}
+	if len(h.caCert) > 0 {
+		files["ca.pem"], files["cert.pem"], files["key.pem"] = h.caCert, h.clientCert, h.clientKey
+	}
+	if len(h.sshKey) > 0 {
+		checking := "accept-new"
+		if len(h.knownHosts) > 0 {
+			checking = "yes"
+			files["known_hosts"] = h.knownHosts
+		}
+		files["id"] = h.sshKey
+		files["ssh_config"] = []byte(fmt.Sprintf("Host *\n  IdentityFile %q\n  IdentitiesOnly yes\n  UserKnownHostsFile %q\n  StrictHostKeyChecking %s\n  BatchMode yes\n",
+			filepath.Join(dir, "id"), filepath.Join(dir, "known_hosts"), checking))
+		files[filepath.Join("bin", "ssh")] = []byte(fmt.Sprintf("#!/bin/sh\nPATH=%s exec ssh -F %s \"$@\"\n",
+			shellQuote(path), shellQuote(filepath.Join(dir, "ssh_config"))))
+	}
+
+	env := h.environment(path)
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	wrapper := "#!/bin/sh\nunset " + strings.Join(unsetEnvVars, " ") + "\n"
+	for _, key := range keys {
+		wrapper += fmt.Sprintf("export %s=%s\n", key, shellQuote(env[key]))
+	}
+	files["docker"] = []byte(wrapper + "exec docker \"$@\"\n")
+	return files
+}
+
+// writeFiles writes the files of the host into its directory, readable by the controller only. Each file is
+// replaced at once, the commands running on the host never read a partial one.
+func (h *Host) writeFiles() error {
+	dir := h.dir()
+	for name, data := range h.files(os.Getenv("PATH")) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return err
+		}
+		mode := os.FileMode(0600)
+		if name == "docker" || filepath.Dir(name) == "bin" {
+			mode = 0700
+		}
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, data, mode); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shellQuote quotes s as a single word for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// hostUsers counts the users of each host, by name: the files of a host are removed with its last user
+var hostUsers = struct {
+	sync.Mutex
+	count map[string]int
+}{count: map[string]int{}}
+
+// AcquireHost writes the docker CLI of the given host and its credentials, nothing for the container host of the
+// controller (nil). They are removed once every user released the host: hosts are used concurrently, each
+// by any number of helpers.
+func AcquireHost(host *Host) (release func(), err error) {
+	if host == nil {
+		return func() {}, nil
+	}
+	hostUsers.Lock()
+	defer hostUsers.Unlock()
+	// written for every user, the credentials of the Secret may have been rotated
+	if err := host.writeFiles(); err != nil {
+		if hostUsers.count[host.Name] == 0 {
+			_ = os.RemoveAll(host.dir())
+		}
+		return nil, errors.Wrapf(err, "cannot write the docker CLI of container host %s", host.Name)
+	}
+	hostUsers.count[host.Name]++
+
+	var once sync.Once
+	return func() { once.Do(func() { releaseHost(host.Name) }) }, nil
+}
+
+func releaseHost(name string) {
+	hostUsers.Lock()
+	defer hostUsers.Unlock()
+	hostUsers.count[name]--
+	if hostUsers.count[name] == 0 {
+		delete(hostUsers.count, name)
+		_ = os.RemoveAll(filepath.Join(hostsDir, filepath.FromSlash(name)))
+	}
+}
+
+// ForgetHost removes the files of the given host, <namespace>/<name> of its KindHost, unless it is in use
+func ForgetHost(name string) error {
+	hostUsers.Lock()
+	defer hostUsers.Unlock()
+	if hostUsers.count[name] > 0 {
+		return nil
+	}
+	return os.RemoveAll(filepath.Join(hostsDir, filepath.FromSlash(name)))
+}
+
+// SweepHosts removes the files of the hosts left behind by a previous run of the controller, they hold
+// the credentials of the hosts
+func SweepHosts(logger logr.Logger) error {
+	if _, err := os.Stat(hostsDir); os.IsNotExist(err) {
+		return nil
+	}
+	if err := os.RemoveAll(hostsDir); err != nil {
+		return errors.Wrapf(err, "cannot remove stale container host credentials %s", hostsDir)
+	}
+	logger.Info("Removed stale container host credentials", "path", hostsDir)
+	return nil
+}
+
+// AcquireHost writes the docker CLI of the container host of the helper, see AcquireHost
+func (k *KindLibHelper) AcquireHost() (release func(), err error) {
+	return AcquireHost(k.host)
+}
+
+// HostInfo is the version and the capacity of a docker daemon, as reported by "docker info"
+type HostInfo struct {
+	Version           string   `json:"ServerVersion"`
+	CPUs              int32    `json:"NCPU"`
+	Memory            int64    `json:"MemTotal"`
+	RunningContainers int32    `json:"ContainersRunning"`
+	ServerErrors      []string `json:"ServerErrors"`
+}
+
+// InspectHost returns the version and the capacity of the docker daemon of the given container host, acquired
+func InspectHost(ctx context.Context, host *Host) (*HostInfo, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, host.cli(v1beta1.DockerRuntime), "info", "--format", "{{json .}}")
+	cmd.SetStdout(&stdout)
+	cmd.SetStderr(&stderr)
+	runErr := cmd.Run()
+
+	info := &HostInfo{}
+	if err := json.Unmarshal(stdout.Bytes(), info); err != nil && runErr == nil {
+		return nil, errors.Wrap(err, "cannot decode docker info")
+	}
+	if len(info.ServerErrors) > 0 {
+		return nil, errors.Errorf("cannot reach the docker daemon: %s", strings.Join(info.ServerErrors, ", "))
+	}
+	if runErr != nil || info.Version == "" {
+		return nil, errors.Errorf("cannot reach the docker daemon: %s", strings.TrimSpace(stderr.String()))
+	}
+	return info, nil
+}
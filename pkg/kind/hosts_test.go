@@ -0,0 +1,146 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	. "github.com/onsi/gomega"
+)
+
+// withHostsDir writes the files of the hosts to a temporary directory for the test, with a fake docker CLI
+// printing its environment first in the PATH
+func withHostsDir(t *testing.T) {
+	original := hostsDir
+	hostsDir = filepath.Join(t.TempDir(), "kind-hosts")
+	t.Cleanup(func() { hostsDir = original })
+
+	bin := t.TempDir()
+	docker := "#!/bin/sh\necho DOCKER_HOST=$DOCKER_HOST DOCKER_TLS_VERIFY=$DOCKER_TLS_VERIFY DOCKER_CERT_PATH=$DOCKER_CERT_PATH DOCKER_CONTEXT=$DOCKER_CONTEXT\n"
+	if err := os.WriteFile(filepath.Join(bin, "docker"), []byte(docker), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("DOCKER_CONTEXT", "local")
+}
+
+func TestHostEnvironment(t *testing.T) {
+	withHostsDir(t)
+
+	tests := []struct {
+		name      string
+		host      *Host
+		wantEnv   string
+		wantFiles []string
+	}{
+		{
+			name:      "tcp",
+			host:      &Host{Name: "default/tcp", Endpoint: "tcp://10.0.0.1:2375"},
+			wantEnv:   "DOCKER_HOST=tcp://10.0.0.1:2375 DOCKER_TLS_VERIFY= DOCKER_CERT_PATH= DOCKER_CONTEXT=",
+			wantFiles: []string{"docker"},
+		},
+		{
+			name:      "tcp with tls",
+			host:      &Host{Name: "default/tls", Endpoint: "tcp://10.0.0.2:2376", caCert: []byte("ca"), clientCert: []byte("cert"), clientKey: []byte("key")},
+			wantEnv:   "DOCKER_HOST=tcp://10.0.0.2:2376 DOCKER_TLS_VERIFY=1 DOCKER_CERT_PATH=<dir> DOCKER_CONTEXT=",
+			wantFiles: []string{"docker", "ca.pem", "cert.pem", "key.pem"},
+		},
+		{
+			name:      "ssh",
+			host:      &Host{Name: "other/ssh", Endpoint: "ssh://builder@10.0.0.3", sshKey: []byte("id"), knownHosts: []byte("known")},
+			wantEnv:   "DOCKER_HOST=ssh://builder@10.0.0.3 DOCKER_TLS_VERIFY= DOCKER_CERT_PATH= DOCKER_CONTEXT=",
+			wantFiles: []string{"docker", "id", "known_hosts", "ssh_config", "bin/ssh"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			release, err := AcquireHost(tt.host)
+			g.Expect(err).NotTo(HaveOccurred())
+			defer release()
+
+			dir := tt.host.dir()
+			info, err := os.Stat(dir)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(info.Mode().Perm()).To(Equal(os.FileMode(0700)))
+			for _, name := range tt.wantFiles {
+				g.Expect(filepath.Join(dir, name)).To(BeAnExistingFile())
+			}
+
+			out, err := osexec.Command(tt.host.cli(v1beta1.DockerRuntime), "info").Output()
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(strings.TrimSpace(string(out))).To(Equal(strings.ReplaceAll(tt.wantEnv, "<dir>", dir)))
+
+			if len(tt.host.sshKey) > 0 {
+				g.Expect(tt.host.environment("/usr/bin")).To(HaveKeyWithValue("PATH", filepath.Join(dir, "bin")+":/usr/bin"))
+			}
+		})
+	}
+
+	// the environment of the process selects the daemon of the controller
+	g := NewWithT(t)
+	g.Expect(os.Getenv("DOCKER_HOST")).To(BeEmpty())
+	g.Expect(os.Getenv("DOCKER_CONTEXT")).To(Equal("local"))
+	g.Expect((*Host)(nil).cli(v1beta1.PodmanRuntime)).To(Equal("podman"))
+}
+
+func TestAcquireHost(t *testing.T) {
+	withHostsDir(t)
+	g := NewWithT(t)
+
+	a := &Host{Name: "default/a", Endpoint: "tcp://10.0.0.1:2375"}
+	b := &Host{Name: "default/b", Endpoint: "tcp://10.0.0.2:2375"}
+
+	// hosts are acquired concurrently, each by any number of users
+	releaseA1, err := AcquireHost(a)
+	g.Expect(err).NotTo(HaveOccurred())
+	releaseA2, err := AcquireHost(a)
+	g.Expect(err).NotTo(HaveOccurred())
+	releaseB, err := AcquireHost(b)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(a.dir()).To(BeADirectory())
+	g.Expect(b.dir()).To(BeADirectory())
+
+	// the files are removed with the last user, releasing twice does not count
+	releaseA1()
+	releaseA1()
+	g.Expect(a.dir()).To(BeADirectory())
+	releaseA2()
+	g.Expect(a.dir()).NotTo(BeADirectory())
+	g.Expect(b.dir()).To(BeADirectory())
+
+	// hosts in use are not forgotten
+	g.Expect(ForgetHost(b.Name)).To(Succeed())
+	g.Expect(b.dir()).To(BeADirectory())
+	releaseB()
+	g.Expect(b.dir()).NotTo(BeADirectory())
+	g.Expect(hostUsers.count).To(BeEmpty())
+
+	// the container host of the controller has no files
+	release, err := AcquireHost(nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	release()
+	entries, err := os.ReadDir(filepath.Join(hostsDir, "default"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(entries).To(BeEmpty())
+}
@@ -0,0 +1,41 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/mbovo/cluster-api-provider-kind/pkg/nodeimage"
+)
+
+// NodeImage returns the node image the kind cluster should run: the image resolved from the spec
+// (see ResolveNodeImage) once recorded in the status, the image of the spec otherwise
+func NodeImage(kindCluster *v1beta1.KindCluster) string {
+	if kindCluster.Status.ResolvedImage != "" {
+		return kindCluster.Status.ResolvedImage
+	}
+	return kindCluster.Spec.Image
+}
+
+// ResolveNodeImage returns the node image for the given KindCluster.
+// Without spec.k8sVersion the image of the spec is used as is. Otherwise the image for that version is resolved
+// by nodeimage.Resolve in the repository of spec.image, overrides is the content of the node images override ConfigMap.
+func ResolveNodeImage(kindCluster *v1beta1.KindCluster, overrides map[string]string) (string, error) {
+	if kindCluster.Spec.K8sVersion == "" {
+		return kindCluster.Spec.Image, nil
+	}
+	return nodeimage.Resolve(kindCluster.Spec.Image, kindCluster.Spec.K8sVersion, overrides)
+}
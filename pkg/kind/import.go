@@ -0,0 +1,66 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"context"
+
+	"github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+	"sigs.k8s.io/kind/pkg/cluster/nodeutils"
+)
+
+// Topology is the node layout of an existing kind cluster, as found in its containers
+type Topology struct {
+	// Number of control plane nodes
+	ControlPlanes int32
+	// Number of worker nodes
+	Workers int32
+	// Image of the bootstrap control plane node
+	Image string
+}
+
+// Topology reads the nodes and the image of the kind cluster back from its containers, e.g. to import a kind cluster
+// created with the kind CLI
+func (k *KindLibHelper) Topology(ctx context.Context, kindCluster *v1beta1.KindCluster) (*Topology, error) {
+	clusterName := ClusterName(kindCluster)
+	allNodes, err := k.listNodes(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	controlPlane, err := bootstrapControlPlaneNode(clusterName, allNodes)
+	if err != nil {
+		return nil, err
+	}
+	controlPlanes, err := nodeutils.SelectNodesByRole(allNodes, constants.ControlPlaneNodeRoleValue)
+	if err != nil {
+		return nil, err
+	}
+	workers, err := nodeutils.SelectNodesByRole(allNodes, constants.WorkerNodeRoleValue)
+	if err != nil {
+		return nil, err
+	}
+	image, _, err := k.inspectNode(ctx, controlPlane.String())
+	if err != nil {
+		return nil, err
+	}
+	return &Topology{
+		ControlPlanes: int32(len(controlPlanes)),
+		Workers:       int32(len(workers)),
+		Image:         image,
+	}, nil
+}
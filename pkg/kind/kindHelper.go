@@ -20,6 +20,7 @@ import (
 	"context"
 
 	"github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
 type KindHelper interface {
@@ -27,4 +28,25 @@ type KindHelper interface {
 	Endpoint(ctx context.Context, kindCluster *v1beta1.KindCluster) (host string, port int, err error)
 	Create(ctx context.Context, kindCluster *v1beta1.KindCluster) (err error)
 	Delete(ctx context.Context, kindCluster *v1beta1.KindCluster) (err error)
+	KubeConfig(ctx context.Context, kindCluster *v1beta1.KindCluster) (kubeconfig []byte, err error)
+	IsOwned(ctx context.Context, kindCluster *v1beta1.KindCluster) (bool, error)
+	ControlPlaneReady(ctx context.Context, kindCluster *v1beta1.KindCluster) (bool, error)
+	CountNodes(ctx context.Context, kindCluster *v1beta1.KindCluster) (int32, error)
+	Workers(ctx context.Context, kindCluster *v1beta1.KindCluster) ([]string, error)
+	AddWorker(ctx context.Context, kindCluster *v1beta1.KindCluster) (name string, err error)
+	RemoveWorker(ctx context.Context, kindCluster *v1beta1.KindCluster, name string) error
+	AddWorkerNode(ctx context.Context, kindCluster *v1beta1.KindCluster, kindMachine *v1beta1.KindMachine, name string, image string) error
+	NodeExists(ctx context.Context, kindCluster *v1beta1.KindCluster, name string) (bool, error)
+	NodeAddresses(ctx context.Context, kindCluster *v1beta1.KindCluster, name string) ([]clusterv1.MachineAddress, error)
+	KubeVersion(ctx context.Context, kindCluster *v1beta1.KindCluster) (string, error)
+	ImageKubeVersion(ctx context.Context, image string) (string, error)
+	Upgrade(ctx context.Context, kindCluster *v1beta1.KindCluster, image string, progress func(updated int32)) error
+	ReconcileRegistry(ctx context.Context, kindCluster *v1beta1.KindCluster) error
+	DeleteRegistry(ctx context.Context, kindCluster *v1beta1.KindCluster) error
+	PreloadImages(ctx context.Context, kindCluster *v1beta1.KindCluster) ([]v1beta1.PreloadedImage, error)
+	NodeDrift(ctx context.Context, kindCluster *v1beta1.KindCluster) (*NodeDrift, error)
+	StartNodes(ctx context.Context, kindCluster *v1beta1.KindCluster, names []string) error
+	RecreateWorker(ctx context.Context, kindCluster *v1beta1.KindCluster, name string) error
+	Topology(ctx context.Context, kindCluster *v1beta1.KindCluster) (*Topology, error)
+	AcquireHost() (release func(), err error)
 }
@@ -18,40 +18,55 @@ package kind
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 	"strings"
-	"time"
 
 	"net/url"
 
 	"github.com/go-logr/logr"
 	"github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
 	"github.com/mbovo/cluster-api-provider-kind/pkg/kubeconfig"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/version"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	v1alpha4Kind "sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
 	kindApiCluster "sigs.k8s.io/kind/pkg/cluster"
+	"sigs.k8s.io/kind/pkg/cluster/nodeutils"
+	"sigs.k8s.io/kind/pkg/exec"
 )
 
 type KindLibHelper struct {
 	Provider *kindApiCluster.Provider
 	Config   *v1alpha4Kind.Cluster
+
+	// container runtime CLI used to manage node containers, the docker CLI of remote hosts
+	runtime string
+	// remote container host of the kind cluster, nil for the container host of the controller
+	host *Host
 }
 
-func NewKindLibHelper(inlog logr.Logger, kindCluster *v1beta1.KindCluster, capiCluster *clusterv1.Cluster) *KindLibHelper {
+// NewKindLibHelper returns a KindLibHelper managing the kind cluster of the KindCluster with the given container runtime,
+// see ResolveRuntime, on the given container host. The host must be acquired while using the helper, see AcquireHost.
+func NewKindLibHelper(inlog logr.Logger, kindCluster *v1beta1.KindCluster, capiCluster *clusterv1.Cluster, runtime v1beta1.ContainerRuntime, host *Host) *KindLibHelper {
 
-	// This LoggerWrapper wraps logr.Logger in a Kind Logger fashion to make kind log to the controller logs
-	logger := NewLoggerWrapper()
-	provider := kindApiCluster.NewProvider(kindApiCluster.ProviderWithLogger(logger), kindApiCluster.ProviderWithDocker())
+	// This LoggerWrapper wraps logr.Logger in a Kind Logger fashion to make kind log to the controller logs,
+	// attached to the KindCluster of the reconciliation
+	logger := NewLoggerWrapper(inlog)
+	provider := kindApiCluster.NewProvider(kindApiCluster.ProviderWithLogger(logger), providerOption(runtime, host))
 	config := newClusterConfig(kindCluster, capiCluster)
-	return &KindLibHelper{Provider: provider, Config: config}
+	if host != nil {
+		host.configure(config)
+	}
+	return &KindLibHelper{Provider: provider, Config: config, runtime: host.cli(runtime), host: host}
 }
 
 func (k *KindLibHelper) Exists(ctx context.Context, kindCluster *v1beta1.KindCluster) (bool, error) {
-	clusterName := kindCluster.Name
+	clusterName := ClusterName(kindCluster)
 	clusters, err := k.Provider.List()
 	if err != nil {
-		return false, nil
+		return false, classify(ErrRuntimeUnavailable, err)
 	}
 	for _, cluster := range clusters {
 		if cluster == clusterName {
@@ -61,63 +76,211 @@ func (k *KindLibHelper) Exists(ctx context.Context, kindCluster *v1beta1.KindClu
 	return false, nil
 }
 
+// Endpoint returns the address and port the API server of the kind cluster is reached at, from the kubeconfig
+// of the provider, see KubeConfig. Errors are ErrEndpointUnavailable.
 func (k *KindLibHelper) Endpoint(ctx context.Context, kindCluster *v1beta1.KindCluster) (host string, port int, err error) {
 	logger := log.FromContext(ctx)
-	logger.Info("Getting Kind cluster endpoint", "cluster", kindCluster.Name)
-	str, err := k.Provider.KubeConfig(kindCluster.Name, false)
+	logger.Info("Getting Kind cluster endpoint", "cluster", ClusterName(kindCluster))
+	str, err := k.kubeConfig(kindCluster)
+	if err != nil {
+		return "", 0, classify(ErrEndpointUnavailable, err)
+	}
 	kubeCfg, err := kubeconfig.Decode([]byte(str))
-
-	if kubeCfg != nil {
-		url, err := url.Parse(kubeCfg.Clusters[0].Cluster.Server)
-		if err != nil {
-			return "", 0, err
-		}
-		host = url.Hostname()
-		port, _ = strconv.Atoi(url.Port())
+	if err != nil {
+		return "", 0, classify(ErrEndpointUnavailable, err)
+	}
+	if len(kubeCfg.Clusters) == 0 {
+		return "", 0, classify(ErrEndpointUnavailable, errors.Errorf("no cluster in the kubeconfig of kind cluster %s", ClusterName(kindCluster)))
 	}
 
-	return
+	url, err := url.Parse(kubeCfg.Clusters[0].Cluster.Server)
+	if err != nil {
+		return "", 0, classify(ErrEndpointUnavailable, err)
+	}
+	port, err = strconv.Atoi(url.Port())
+	if err != nil {
+		return "", 0, classify(ErrEndpointUnavailable, errors.Wrapf(err, "invalid API server port of kind cluster %s", ClusterName(kindCluster)))
+	}
+	return url.Hostname(), port, nil
 }
 
 func (k *KindLibHelper) Create(ctx context.Context, kindCluster *v1beta1.KindCluster) (err error) {
 	logger := log.FromContext(ctx)
-	logger.Info("Creating Kind cluster", "cluster", kindCluster.Name)
+	clusterName := ClusterName(kindCluster)
+	logger.Info("Creating Kind cluster", "cluster", clusterName)
 
+	// do not wait for the control plane to be ready, the readiness is checked later with ControlPlaneReady
 	clusterCfg := kindApiCluster.CreateWithV1Alpha4Config(k.Config)
-	waitForReady := kindApiCluster.CreateWithWaitForReady(120 * time.Second)
-	kubeConfigPath := kindApiCluster.CreateWithKubeconfigPath("/tmp/kind-config-" + kindCluster.Name)
 
-	err = k.Provider.Create(kindCluster.Name, clusterCfg, waitForReady, kubeConfigPath)
+	// kind refuses to create a cluster whose nodes exist, they are not ours to clean up then
+	existing, err := k.listNodes(clusterName)
+	if err != nil {
+		return err
+	}
 
-	return
+	return withKubeconfigPath(func(path string) error {
+		err := k.Provider.Create(clusterName, clusterCfg, kindApiCluster.CreateWithKubeconfigPath(path))
+		if err == nil || len(existing) > 0 {
+			return err
+		}
+		// remove the containers left behind by the failed creation, so that the next attempt starts afresh
+		logger.Info("Cleaning up failed Kind cluster", "cluster", clusterName, "reason", err.Error())
+		if deleteErr := k.Provider.Delete(clusterName, path); deleteErr != nil {
+			logger.Error(deleteErr, "Failed to clean up failed Kind cluster", "cluster", clusterName)
+		}
+		return err
+	})
 }
 
 func (k *KindLibHelper) Delete(ctx context.Context, kindCluster *v1beta1.KindCluster) (err error) {
 	logger := log.FromContext(ctx)
-	logger.Info("Deleting Kind cluster", "cluster", kindCluster.Name)
+	clusterName := ClusterName(kindCluster)
+	logger.Info("Deleting Kind cluster", "cluster", clusterName)
+
+	return withKubeconfigPath(func(path string) error {
+		return k.Provider.Delete(clusterName, path)
+	})
+}
+
+// KubeConfig returns the kubeconfig of the kind cluster, as generated by kind, reachable from the docker host,
+// from anywhere for remote hosts
+func (k *KindLibHelper) KubeConfig(ctx context.Context, kindCluster *v1beta1.KindCluster) (kubeconfig []byte, err error) {
+	logger := log.FromContext(ctx)
+	logger.Info("Getting Kind cluster kubeconfig", "cluster", ClusterName(kindCluster))
+
+	str, err := k.kubeConfig(kindCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(str), nil
+}
+
+// kubeConfig returns the kubeconfig generated by kind, pointing to the address of the host of a remote kind cluster.
+// kind fails the same way when the runtime is unavailable or the cluster missing, the nodes are listed to tell.
+func (k *KindLibHelper) kubeConfig(kindCluster *v1beta1.KindCluster) (string, error) {
+	clusterName := ClusterName(kindCluster)
+	str, err := k.Provider.KubeConfig(clusterName, false)
+	if err != nil {
+		allNodes, listErr := k.listNodes(clusterName)
+		if listErr != nil {
+			return "", listErr
+		}
+		if len(allNodes) == 0 {
+			return "", classify(ErrClusterNotFound, err)
+		}
+		return "", err
+	}
+	if k.host == nil {
+		return str, nil
+	}
+	return k.host.server(str), nil
+}
+
+// IsOwned reports whether the nodes of the kind cluster carry the UID of the given KindCluster, i.e. the kind cluster
+// has been created for this KindCluster and not by someone else with the same name
+func (k *KindLibHelper) IsOwned(ctx context.Context, kindCluster *v1beta1.KindCluster) (bool, error) {
+	logger := log.FromContext(ctx)
+	clusterName := ClusterName(kindCluster)
+	logger.Info("Checking Kind cluster ownership", "cluster", clusterName)
 
-	err = k.Provider.Delete(kindCluster.Name, "/tmp/kind-config-"+kindCluster.Name)
+	allNodes, err := k.listNodes(clusterName)
+	if err != nil {
+		return false, err
+	}
+	node, err := bootstrapControlPlaneNode(clusterName, allNodes)
+	if err != nil {
+		return false, err
+	}
+
+	selector := fmt.Sprintf("%s=%s", v1beta1.KindClusterUIDLabel, kindCluster.UID)
+	cmd := node.CommandContext(ctx, "kubectl", "--kubeconfig=/etc/kubernetes/admin.conf", "get", "nodes", "--selector", selector, "--output", "name")
+	lines, err := exec.OutputLines(cmd)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to list owned nodes")
+	}
 
-	return
+	return len(lines) > 0, nil
+}
+
+// ControlPlaneReady reports whether all the control plane nodes of the kind cluster are Ready
+func (k *KindLibHelper) ControlPlaneReady(ctx context.Context, kindCluster *v1beta1.KindCluster) (bool, error) {
+	clusterName := ClusterName(kindCluster)
+
+	allNodes, err := k.listNodes(clusterName)
+	if err != nil {
+		return false, err
+	}
+	node, err := bootstrapControlPlaneNode(clusterName, allNodes)
+	if err != nil {
+		return false, err
+	}
+
+	rawVersion, err := nodeutils.KubeVersion(node)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get Kubernetes version from node")
+	}
+	kubeVersion, err := version.ParseSemantic(rawVersion)
+	if err != nil {
+		return false, errors.Wrap(err, "could not parse Kubernetes version")
+	}
+	// same as kind: control plane nodes are labeled as master before 1.24
+	selectorLabel := "node-role.kubernetes.io/control-plane"
+	if kubeVersion.LessThan(version.MustParseSemantic("v1.24.0-alpha.1.591+a3d5e5598290df")) {
+		selectorLabel = "node-role.kubernetes.io/master"
+	}
+
+	cmd := node.CommandContext(ctx, "kubectl", "--kubeconfig=/etc/kubernetes/admin.conf", "get", "nodes",
+		"--selector="+selectorLabel, "-o=jsonpath={.items..status.conditions[-1:].status}")
+	lines, err := exec.OutputLines(cmd)
+	if err != nil || len(lines) == 0 {
+		// the API server is not answering yet
+		return false, nil
+	}
+
+	// one status per control plane node, e.g. "True True True"
+	status := strings.Fields(lines[0])
+	if len(status) == 0 {
+		return false, nil
+	}
+	for _, s := range status {
+		if s != "True" {
+			return false, nil
+		}
+	}
+	return true, nil
 }
 
 func newClusterConfig(kindCluster *v1beta1.KindCluster, capiCluster *clusterv1.Cluster) *v1alpha4Kind.Cluster {
 
 	cfg := &v1alpha4Kind.Cluster{}
-	cfg.Name = kindCluster.Name
+	cfg.Name = ClusterName(kindCluster)
+
+	image := NodeImage(kindCluster)
 
-	image := kindCluster.Spec.Image
-	if kindCluster.Spec.K8sVersion != "" {
-		img := strings.Split(image, ":")[0]
-		image = img + ":" + kindCluster.Spec.K8sVersion
+	cfg.Networking = newNetworking(kindCluster, capiCluster)
+	cfg.KubeadmConfigPatches = kindCluster.Spec.KubeadmConfigPatches
+	cfg.KubeadmConfigPatchesJSON6902 = kindPatchesJSON6902(kindCluster.Spec.KubeadmConfigPatchesJSON6902)
+	cfg.ContainerdConfigPatches = kindCluster.Spec.ContainerdConfigPatches
+	if kindCluster.Spec.Registry != nil {
+		cfg.ContainerdConfigPatches = append(append([]string{}, cfg.ContainerdConfigPatches...), registryContainerdPatch(kindCluster))
 	}
+	cfg.ContainerdConfigPatchesJSON6902 = kindCluster.Spec.ContainerdConfigPatchesJSON6902
+	cfg.FeatureGates = kindCluster.Spec.FeatureGates
+	cfg.RuntimeConfig = kindCluster.Spec.RuntimeConfig
+
+	// every node is labeled with the KindCluster UID to recognize the clusters we own
+	labels := map[string]string{v1beta1.KindClusterUIDLabel: string(kindCluster.UID)}
 
 	// adding nodes to the kind cluster
 	for i := 0; i < int(kindCluster.Spec.ControlPlaneCount); i++ {
-		cfg.Nodes = append(cfg.Nodes, v1alpha4Kind.Node{Role: v1alpha4Kind.ControlPlaneRole, Image: image})
+		cfg.Nodes = append(cfg.Nodes, v1alpha4Kind.Node{Role: v1alpha4Kind.ControlPlaneRole, Image: image, Labels: labels})
+	}
+	for i := range kindCluster.Spec.NodePools {
+		cfg.Nodes = append(cfg.Nodes, nodePoolNodes(&kindCluster.Spec.NodePools[i], image, labels)...)
 	}
 	for i := 0; i < int(kindCluster.Spec.WorkerCount); i++ {
-		cfg.Nodes = append(cfg.Nodes, v1alpha4Kind.Node{Role: v1alpha4Kind.WorkerRole, Image: image})
+		cfg.Nodes = append(cfg.Nodes, v1alpha4Kind.Node{Role: v1alpha4Kind.WorkerRole, Image: image, Labels: labels})
 	}
 
 	return cfg
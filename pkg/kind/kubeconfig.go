@@ -0,0 +1,64 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+)
+
+// staleKubeconfigs matches the kubeconfig files, and their lock files, written by previous versions of the controller
+var staleKubeconfigs = "/tmp/kind-config-*"
+
+// kubeconfigDirs is the parent directory and the name prefix of the directories of withKubeconfigPath
+var kubeconfigDirs = struct{ parent, prefix string }{parent: os.TempDir(), prefix: "kind-kubeconfig-"}
+
+// withKubeconfigPath runs fn with the path of a kubeconfig file in a private directory removed when fn returns.
+// kind merges the kubeconfig of the clusters it creates into a file and removes it from the file when deleting them,
+// the controller never reads the file: the kubeconfig is read from the nodes of the cluster, see KubeConfig.
+func withKubeconfigPath(fn func(path string) error) error {
+	dir, err := os.MkdirTemp(kubeconfigDirs.parent, kubeconfigDirs.prefix)
+	if err != nil {
+		return errors.Wrap(err, "cannot create kubeconfig directory")
+	}
+	defer os.RemoveAll(dir)
+	return fn(filepath.Join(dir, "config"))
+}
+
+// SweepKubeconfigs removes the kubeconfig files left in /tmp by previous versions of the controller, and the
+// directories of withKubeconfigPath left by a controller killed while creating or deleting a kind cluster:
+// they hold the credentials of kind clusters
+func SweepKubeconfigs(logger logr.Logger) error {
+	paths, err := filepath.Glob(staleKubeconfigs)
+	if err != nil {
+		return err
+	}
+	dirs, err := filepath.Glob(filepath.Join(kubeconfigDirs.parent, kubeconfigDirs.prefix+"*"))
+	if err != nil {
+		return err
+	}
+	for _, path := range append(paths, dirs...) {
+		if err := os.RemoveAll(path); err != nil {
+			return errors.Wrapf(err, "cannot remove stale kubeconfig %s", path)
+		}
+		logger.Info("Removed stale kubeconfig", "path", path)
+	}
+	return nil
+}
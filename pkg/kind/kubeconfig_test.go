@@ -0,0 +1,57 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+)
+
+func TestSweepKubeconfigs(t *testing.T) {
+	g := NewWithT(t)
+	tmp := t.TempDir()
+	originalStale, originalDirs := staleKubeconfigs, kubeconfigDirs
+	staleKubeconfigs = filepath.Join(tmp, "kind-config-*")
+	kubeconfigDirs.parent = tmp
+	t.Cleanup(func() { staleKubeconfigs, kubeconfigDirs = originalStale, originalDirs })
+
+	// the kubeconfig is written to a private directory removed once used
+	g.Expect(withKubeconfigPath(func(path string) error {
+		g.Expect(filepath.Dir(path)).To(HavePrefix(filepath.Join(tmp, "kind-kubeconfig-")))
+		return os.WriteFile(path, []byte("credentials"), 0o600)
+	})).To(Succeed())
+	entries, err := os.ReadDir(tmp)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(entries).To(BeEmpty())
+
+	// a kubeconfig of a previous version, and one left by a controller killed during a creation
+	g.Expect(os.WriteFile(filepath.Join(tmp, "kind-config-dev"), []byte("credentials"), 0o600)).To(Succeed())
+	left, err := os.MkdirTemp(tmp, "kind-kubeconfig-")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(os.WriteFile(filepath.Join(left, "config"), []byte("credentials"), 0o600)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(tmp, "other"), nil, 0o600)).To(Succeed())
+
+	g.Expect(SweepKubeconfigs(logr.Discard())).To(Succeed())
+	entries, err = os.ReadDir(tmp)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(entries).To(HaveLen(1))
+	g.Expect(entries[0].Name()).To(Equal("other"))
+}
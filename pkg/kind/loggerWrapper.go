@@ -21,23 +21,13 @@ import (
 	"strings"
 
 	"github.com/go-logr/logr"
-	"github.com/go-logr/logr/funcr"
 	kindLog "sigs.k8s.io/kind/pkg/log"
 )
 
-// NewStdoutLogger returns a logr.Logger that prints to stdout.
-func NewStdoutLogger() logr.Logger {
-	return funcr.New(func(prefix, args string) {
-		if prefix != "" {
-			_ = fmt.Sprintf("%s: %s\n", prefix, args)
-		} else {
-			fmt.Println(args)
-		}
-	}, funcr.Options{})
-}
-
-func NewLoggerWrapper() LoggerWrapper {
-	return LoggerWrapper{log: NewStdoutLogger()}
+// NewLoggerWrapper returns a kind logger writing to the given logger, usually the one of the reconciliation
+// with the KindCluster it is about, under the "kind" name
+func NewLoggerWrapper(log logr.Logger) LoggerWrapper {
+	return LoggerWrapper{log: log.WithName("kind")}
 }
 
 // LoggerWrapper implement a wrapper from kind logger to logr.Loggger used by controller runtime
@@ -45,37 +35,43 @@ type LoggerWrapper struct {
 	log logr.Logger
 }
 
-// Implemente the Kind Logger interface calling the beneath log.Error
+// Error logs the message as the error, as kind has no error value to pass
 func (l LoggerWrapper) Error(message string) {
-	l.log.Error(errors.New(strings.ToLower(message)), "")
+	l.log.Error(errors.New(strings.TrimSpace(message)), "kind error")
 }
 
+// Errorf logs the formatted message as the error, wrapped errors included
 func (l LoggerWrapper) Errorf(format string, args ...interface{}) {
-	l.log.Error(errors.New("error"), fmt.Sprintf(format, args...))
+	l.log.Error(fmt.Errorf(format, args...), "kind error")
 }
 
+// Info logs the user facing messages of kind, its progress mostly
 func (l LoggerWrapper) Info(message string) {
-	l.log.Info(message)
+	l.log.Info(strings.TrimSpace(message))
 }
 
 func (l LoggerWrapper) Infof(format string, args ...interface{}) {
-	l.log.Info(fmt.Sprintf(format, args...))
+	l.Info(fmt.Sprintf(format, args...))
 }
 
+// Warn logs the user facing warnings of kind, at the info level with a warning severity: logr has no warning level
 func (l LoggerWrapper) Warn(message string) {
-	l.log.Info(message)
+	l.log.Info(strings.TrimSpace(message), "severity", "warning")
 }
 
 func (l LoggerWrapper) Warnf(format string, args ...interface{}) {
-	l.log.Info(fmt.Sprintf(format, args...))
+	l.Warn(fmt.Sprintf(format, args...))
 }
 
 func (l LoggerWrapper) Enabled() bool {
 	return l.log.Enabled()
 }
 
+// V returns the logger of a kind verbosity level: the user facing messages of V(0) are info messages,
+// the debug messages of V(1) and above are logr verbosity levels as well
 func (l LoggerWrapper) V(lvl kindLog.Level) (il kindLog.InfoLogger) {
-	// newLog := LoggerWrapper{log: logr.Logger{}.V(int(lvl))}
-	newLog := LoggerWrapper{log: l.log.V(int(lvl))}
-	return newLog
+	if lvl < 0 {
+		lvl = 0
+	}
+	return LoggerWrapper{log: l.log.V(int(lvl))}
 }
@@ -0,0 +1,89 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+)
+
+// MaxClusterNameLength is the maximum length of a kind cluster name, kind derives container and host names from it
+// (e.g. <name>-external-load-balancer) and those must fit in 63 characters.
+const MaxClusterNameLength = 40
+
+// node names are used as host names
+const maxNodeNameLength = 63
+
+// length of the hash suffix appended to generated and truncated names
+const nameHashLength = 8
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9.-]`)
+
+// ClusterName returns the name of the kind cluster backing the given KindCluster.
+// The name recorded in the status wins, so that a cluster is never renamed once created,
+// then the explicit spec.kindClusterName, finally a name derived from namespace and name.
+func ClusterName(kindCluster *v1beta1.KindCluster) string {
+	if kindCluster.Status.KindClusterName != "" {
+		return kindCluster.Status.KindClusterName
+	}
+	if kindCluster.Spec.KindClusterName != "" {
+		return kindCluster.Spec.KindClusterName
+	}
+	return GenerateClusterName(kindCluster.Namespace, kindCluster.Name)
+}
+
+// GenerateClusterName returns a deterministic kind cluster name for the given namespace and name, always suffixed
+// with a hash of namespace and name: the dash joining them may be part of either (team-a/dev and team/a-dev).
+// Names longer than MaxClusterNameLength are truncated before the hash.
+func GenerateClusterName(namespace, name string) string {
+	clusterName := invalidNameChars.ReplaceAllString(strings.ToLower(namespace+"-"+name), "-")
+	return hashName(clusterName, MaxClusterNameLength, namespace+"/"+name)
+}
+
+// MachineNodeName returns the name of the kind node (and of its container) backing the given KindMachine,
+// the KindMachine name prefixed by the kind cluster name and "machine", never taken for a worker name of kind
+func MachineNodeName(kindCluster *v1beta1.KindCluster, kindMachine *v1beta1.KindMachine) string {
+	nodeName := ClusterName(kindCluster) + "-machine-" + invalidNameChars.ReplaceAllString(strings.ToLower(kindMachine.Name), "-")
+	return truncateName(nodeName, maxNodeNameLength, kindMachine.Namespace+"/"+kindMachine.Name)
+}
+
+// RegistryName returns the name of the container of the local image registry of the kind cluster
+func RegistryName(kindCluster *v1beta1.KindCluster) string {
+	return ClusterName(kindCluster) + "-registry"
+}
+
+// truncateName truncates names longer than maxLength, suffixing them with a hash of key to keep them unique
+func truncateName(name string, maxLength int, key string) string {
+	if len(name) <= maxLength {
+		return name
+	}
+	return hashName(name, maxLength, key)
+}
+
+// hashName suffixes the name with a hash of key, truncating it to fit in maxLength
+func hashName(name string, maxLength int, key string) string {
+	sum := sha256.Sum256([]byte(key))
+	hash := hex.EncodeToString(sum[:])[:nameHashLength]
+	if len(name) > maxLength-nameHashLength-1 {
+		name = name[:maxLength-nameHashLength-1]
+	}
+	return strings.TrimRight(name, "-.") + "-" + hash
+}
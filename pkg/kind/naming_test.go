@@ -0,0 +1,101 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGenerateClusterName(t *testing.T) {
+	tests := []struct {
+		namespace  string
+		name       string
+		wantPrefix string
+	}{
+		{namespace: "default", name: "dev", wantPrefix: "default-dev-"},
+		{namespace: "team-a", name: "dev", wantPrefix: "team-a-dev-"},
+		{namespace: "team", name: "a-dev", wantPrefix: "team-a-dev-"},
+		{namespace: "default", name: "Dev_Cluster", wantPrefix: "default-dev-cluster-"},
+		{namespace: "a-very-long-namespace-name", name: "with-a-long-cluster-name", wantPrefix: "a-very-long-namespace-name-with-"},
+		{namespace: "a-very-long-namespace-name", name: "with-another-long-cluster-name", wantPrefix: "a-very-long-namespace-name-with-"},
+	}
+	seen := map[string]string{}
+	for _, tt := range tests {
+		t.Run(tt.namespace+"/"+tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			clusterName := GenerateClusterName(tt.namespace, tt.name)
+			g.Expect(clusterName).To(HavePrefix(tt.wantPrefix))
+			g.Expect(len(clusterName)).To(BeNumerically("<=", MaxClusterNameLength))
+			g.Expect(clusterName).To(MatchRegexp(`^[a-z0-9][a-z0-9.-]*-[0-9a-f]{8}$`))
+			g.Expect(GenerateClusterName(tt.namespace, tt.name)).To(Equal(clusterName))
+
+			g.Expect(seen).NotTo(HaveKey(clusterName), "collides with %s", seen[clusterName])
+			seen[clusterName] = tt.namespace + "/" + tt.name
+		})
+	}
+}
+
+func TestClusterName(t *testing.T) {
+	kindCluster := &v1beta1.KindCluster{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "dev"}}
+	generated := GenerateClusterName("default", "dev")
+
+	tests := []struct {
+		name       string
+		specName   string
+		statusName string
+		want       string
+	}{
+		{name: "generated", want: generated},
+		{name: "spec", specName: "custom", want: "custom"},
+		{name: "status wins over spec", specName: "custom", statusName: "recorded", want: "recorded"},
+		{name: "status wins over generated", statusName: "dev", want: "dev"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			kc := kindCluster.DeepCopy()
+			kc.Spec.KindClusterName = tt.specName
+			kc.Status.KindClusterName = tt.statusName
+			g.Expect(ClusterName(kc)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestMachineNodeName(t *testing.T) {
+	g := NewWithT(t)
+	kindCluster := &v1beta1.KindCluster{Status: v1beta1.KindClusterStatus{KindClusterName: "dev"}}
+
+	short := &v1beta1.KindMachine{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "Worker_0"}}
+	g.Expect(MachineNodeName(kindCluster, short)).To(Equal("dev-machine-worker-0"))
+
+	// not a worker of kind or of scaling
+	worker := &v1beta1.KindMachine{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "worker2"}}
+	g.Expect(MachineNodeName(kindCluster, worker)).To(Equal("dev-machine-worker2"))
+	g.Expect(workerIndex("dev", MachineNodeName(kindCluster, worker))).To(BeZero())
+
+	long := &v1beta1.KindMachine{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: strings.Repeat("worker", 12)}}
+	nodeName := MachineNodeName(kindCluster, long)
+	g.Expect(len(nodeName)).To(BeNumerically("<=", maxNodeNameLength))
+	g.Expect(nodeName).To(MatchRegexp(`^dev-machine-worker.*-[0-9a-f]{8}$`))
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"strings"
+
+	"github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	v1alpha4Kind "sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+)
+
+// newNetworking maps the networking of the KindCluster to the kind configuration.
+// The subnets not set in the KindCluster are taken from the clusterNetwork of the Cluster, if any,
+// the remaining fields are left to the kind defaults.
+func newNetworking(kindCluster *v1beta1.KindCluster, capiCluster *clusterv1.Cluster) v1alpha4Kind.Networking {
+	spec := kindCluster.Spec.Networking
+	networking := v1alpha4Kind.Networking{
+		IPFamily:          v1alpha4Kind.ClusterIPFamily(spec.IPFamily),
+		PodSubnet:         spec.PodSubnet,
+		ServiceSubnet:     spec.ServiceSubnet,
+		DisableDefaultCNI: spec.DisableDefaultCNI,
+		KubeProxyMode:     v1alpha4Kind.ProxyMode(spec.KubeProxyMode),
+		APIServerAddress:  spec.APIServerAddress,
+		APIServerPort:     spec.APIServerPort,
+	}
+
+	// keep the API server port of a recreated cluster, so that its endpoint does not change
+	if networking.APIServerPort == 0 {
+		networking.APIServerPort = kindCluster.Spec.ControlPlaneEndpoint.Port
+	}
+
+	if capiCluster != nil && capiCluster.Spec.ClusterNetwork != nil {
+		clusterNetwork := capiCluster.Spec.ClusterNetwork
+		if networking.PodSubnet == "" && clusterNetwork.Pods != nil {
+			networking.PodSubnet = strings.Join(clusterNetwork.Pods.CIDRBlocks, ",")
+		}
+		if networking.ServiceSubnet == "" && clusterNetwork.Services != nil {
+			networking.ServiceSubnet = strings.Join(clusterNetwork.Services.CIDRBlocks, ",")
+		}
+	}
+
+	return networking
+}
+
+// nodeIP returns the node-ip kubelet argument for the given node addresses, according to the IP family of the cluster
+func nodeIP(kindCluster *v1beta1.KindCluster, ipv4 string, ipv6 string) string {
+	switch kindCluster.Spec.Networking.IPFamily {
+	case v1beta1.IPv6Family:
+		return ipv6
+	case v1beta1.DualStackFamily:
+		return ipv4 + "," + ipv6
+	}
+	if ipv4 == "" {
+		return ipv6
+	}
+	return ipv4
+}
+
+// ipv6Enabled reports whether the nodes of the kind cluster need IPv6
+func ipv6Enabled(kindCluster *v1beta1.KindCluster) bool {
+	family := kindCluster.Spec.Networking.IPFamily
+	return family == v1beta1.IPv6Family || family == v1beta1.DualStackFamily
+}
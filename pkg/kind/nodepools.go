@@ -0,0 +1,188 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	v1alpha4Kind "sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+)
+
+// Worker nodes of the node pools are created by kind before the workerCount workers, so that they take the first
+// worker indexes: scaling workerCount adds and removes workers after them and never touches a pool.
+
+// nodePoolNodes returns the kind nodes of the given pool
+func nodePoolNodes(pool *v1beta1.NodePool, image string, labels map[string]string) []v1alpha4Kind.Node {
+	if pool.Image != "" {
+		image = pool.Image
+	}
+
+	nodeLabels := make(map[string]string, len(labels)+len(pool.Labels))
+	for k, v := range pool.Labels {
+		nodeLabels[k] = v
+	}
+	for k, v := range labels {
+		nodeLabels[k] = v
+	}
+
+	mounts := make([]v1alpha4Kind.Mount, 0, len(pool.ExtraMounts))
+	for _, m := range pool.ExtraMounts {
+		mounts = append(mounts, v1alpha4Kind.Mount{
+			ContainerPath:  m.ContainerPath,
+			HostPath:       m.HostPath,
+			Readonly:       m.ReadOnly,
+			SelinuxRelabel: m.SelinuxRelabel,
+			Propagation:    v1alpha4Kind.MountPropagation(m.Propagation),
+		})
+	}
+	portMappings := make([]v1alpha4Kind.PortMapping, 0, len(pool.ExtraPortMappings))
+	for _, pm := range pool.ExtraPortMappings {
+		portMappings = append(portMappings, v1alpha4Kind.PortMapping{
+			ContainerPort: pm.ContainerPort,
+			HostPort:      pm.HostPort,
+			ListenAddress: pm.ListenAddress,
+			Protocol:      v1alpha4Kind.PortMappingProtocol(pm.Protocol),
+		})
+	}
+
+	patches := append([]string{}, pool.KubeadmConfigPatches...)
+	// kind has no taints setting, they are set in the kubeadm node registration of the nodes
+	if len(pool.Taints) > 0 {
+		patches = append(patches, taintsPatch("JoinConfiguration", pool.Taints))
+		if pool.Role == v1beta1.ControlPlaneRole {
+			patches = append(patches, taintsPatch("InitConfiguration", pool.Taints))
+		}
+	}
+
+	role := v1alpha4Kind.WorkerRole
+	if pool.Role == v1beta1.ControlPlaneRole {
+		role = v1alpha4Kind.ControlPlaneRole
+	}
+	nodes := make([]v1alpha4Kind.Node, 0, pool.Replicas)
+	for i := 0; i < int(pool.Replicas); i++ {
+		nodes = append(nodes, v1alpha4Kind.Node{
+			Role:                         role,
+			Image:                        image,
+			Labels:                       nodeLabels,
+			ExtraMounts:                  mounts,
+			ExtraPortMappings:            portMappings,
+			KubeadmConfigPatches:         patches,
+			KubeadmConfigPatchesJSON6902: kindPatchesJSON6902(pool.KubeadmConfigPatchesJSON6902),
+		})
+	}
+	return nodes
+}
+
+// poolWorkerCount returns the number of worker nodes of the node pools
+func poolWorkerCount(kindCluster *v1beta1.KindCluster) int {
+	count := 0
+	for _, pool := range kindCluster.Spec.NodePools {
+		if pool.Role != v1beta1.ControlPlaneRole {
+			count += int(pool.Replicas)
+		}
+	}
+	return count
+}
+
+// workerPool returns the node pool of the worker with the given index, nil for the workerCount workers
+func workerPool(kindCluster *v1beta1.KindCluster, index int) *v1beta1.NodePool {
+	for i := range kindCluster.Spec.NodePools {
+		pool := &kindCluster.Spec.NodePools[i]
+		if pool.Role == v1beta1.ControlPlaneRole {
+			continue
+		}
+		if index <= int(pool.Replicas) {
+			return pool
+		}
+		index -= int(pool.Replicas)
+	}
+	return nil
+}
+
+// nodePoolRunArgs returns the container runtime arguments for the mounts and port mappings of the pool,
+// the way kind sets them
+func nodePoolRunArgs(kindCluster *v1beta1.KindCluster, pool *v1beta1.NodePool) []string {
+	var args []string
+	if pool == nil {
+		return args
+	}
+
+	for _, m := range pool.ExtraMounts {
+		bind := fmt.Sprintf("%s:%s", m.HostPath, m.ContainerPath)
+		var attrs []string
+		if m.ReadOnly {
+			attrs = append(attrs, "ro")
+		}
+		if m.SelinuxRelabel {
+			attrs = append(attrs, "Z")
+		}
+		switch m.Propagation {
+		case v1beta1.MountPropagationBidirectional:
+			attrs = append(attrs, "rshared")
+		case v1beta1.MountPropagationHostToContainer:
+			attrs = append(attrs, "rslave")
+		}
+		if len(attrs) > 0 {
+			bind = fmt.Sprintf("%s:%s", bind, strings.Join(attrs, ","))
+		}
+		args = append(args, "--volume="+bind)
+	}
+
+	for _, pm := range pool.ExtraPortMappings {
+		listenAddress := pm.ListenAddress
+		if listenAddress == "" {
+			listenAddress = "0.0.0.0"
+			if kindCluster.Spec.Networking.IPFamily == v1beta1.IPv6Family {
+				listenAddress = "::"
+			}
+		}
+		protocol := pm.Protocol
+		if protocol == "" {
+			protocol = v1beta1.PortMappingProtocolTCP
+		}
+		// the container runtime picks a free host port when not set
+		hostPort := ""
+		if pm.HostPort != 0 {
+			hostPort = fmt.Sprintf("%d", pm.HostPort)
+		}
+		args = append(args, fmt.Sprintf("--publish=%s:%d/%s", net.JoinHostPort(listenAddress, hostPort), pm.ContainerPort, strings.ToLower(string(protocol))))
+	}
+	return args
+}
+
+// nodePoolLabels returns the Kubernetes labels of the nodes of the pool as a kubelet node-labels argument
+func nodePoolLabels(pool *v1beta1.NodePool, labels map[string]string) string {
+	all := map[string]string{}
+	if pool != nil {
+		for k, v := range pool.Labels {
+			all[k] = v
+		}
+	}
+	for k, v := range labels {
+		all[k] = v
+	}
+	pairs := make([]string, 0, len(all))
+	for k, v := range all {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
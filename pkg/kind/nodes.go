@@ -0,0 +1,522 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/version"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+	"sigs.k8s.io/kind/pkg/cluster/nodes"
+	"sigs.k8s.io/kind/pkg/cluster/nodeutils"
+	"sigs.k8s.io/kind/pkg/exec"
+)
+
+// The kind library cannot add or remove nodes of an existing cluster, so node containers are managed
+// through the container runtime CLI (docker, podman and nerdctl share the same commands), the same way kind does it.
+
+const (
+	// labels set by kind on every node container
+	clusterLabelKey  = "io.x-k8s.kind.cluster"
+	nodeRoleLabelKey = "io.x-k8s.kind.role"
+
+	// kubeadm configuration file inside the node containers
+	kubeadmConfigPath = "/kind/kubeadm.conf"
+)
+
+// how long to wait for the container runtime of a new node to be up
+const nodeStartTimeout = 60 * time.Second
+
+// CountNodes returns the number of Kubernetes node containers of the kind cluster
+func (k *KindLibHelper) CountNodes(ctx context.Context, kindCluster *v1beta1.KindCluster) (int32, error) {
+	allNodes, err := k.listNodes(ClusterName(kindCluster))
+	if err != nil {
+		return 0, err
+	}
+	internalNodes, err := nodeutils.InternalNodes(allNodes)
+	if err != nil {
+		return 0, err
+	}
+	return int32(len(internalNodes)), nil
+}
+
+// Workers returns the names of the worker node containers of the kind cluster, sorted by index
+func (k *KindLibHelper) Workers(ctx context.Context, kindCluster *v1beta1.KindCluster) ([]string, error) {
+	workers, err := k.workerNodes(ctx, kindCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	// worker nodes of the node pools are not part of spec.workerCount either
+	poolWorkers := poolWorkerCount(kindCluster)
+	clusterName := ClusterName(kindCluster)
+	names := make([]string, 0, len(workers))
+	for _, name := range workers {
+		if workerIndex(clusterName, name) > poolWorkers {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// workerNodes returns the names of the worker nodes created by kind or by scaling, node pools included,
+// sorted by index
+func (k *KindLibHelper) workerNodes(ctx context.Context, kindCluster *v1beta1.KindCluster) ([]string, error) {
+	clusterName := ClusterName(kindCluster)
+	allNodes, err := k.listNodes(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	workers, err := nodeutils.SelectNodesByRole(allNodes, constants.WorkerNodeRoleValue)
+	if err != nil {
+		return nil, err
+	}
+	machines, err := k.machineNodes(ctx, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(workers))
+	for _, n := range workers {
+		names = append(names, n.String())
+	}
+	return selectWorkers(clusterName, names, machines), nil
+}
+
+// selectWorkers returns the worker nodes created by kind or by scaling among the given worker nodes, sorted by index.
+// The nodes of KindMachines are not part of spec.workerCount, whatever their name: the ones created before they
+// were labelled have a name that is not a worker name, unless it collides with one.
+func selectWorkers(clusterName string, workers []string, machines map[string]bool) []string {
+	names := make([]string, 0, len(workers))
+	for _, name := range workers {
+		if !machines[name] && workerIndex(clusterName, name) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return workerIndex(clusterName, names[i]) < workerIndex(clusterName, names[j])
+	})
+	return names
+}
+
+// machineNodes returns the node containers of the kind cluster created for KindMachines, see AddWorkerNode
+func (k *KindLibHelper) machineNodes(ctx context.Context, clusterName string) (map[string]bool, error) {
+	lines, err := exec.OutputLines(exec.CommandContext(ctx, k.runtime, "ps", "--all",
+		"--filter", fmt.Sprintf("label=%s=%s", clusterLabelKey, clusterName),
+		"--filter", "label="+v1beta1.KindMachineUIDLabel,
+		"--format", "{{.Names}}"))
+	if err != nil {
+		return nil, classify(ErrRuntimeUnavailable, errors.Wrap(err, "failed to list the node containers of KindMachines"))
+	}
+	machines := map[string]bool{}
+	for _, line := range lines {
+		if name := strings.TrimSpace(line); name != "" {
+			machines[name] = true
+		}
+	}
+	return machines, nil
+}
+
+// AddWorker creates a new worker node container and joins it to the kind cluster, returning its name
+func (k *KindLibHelper) AddWorker(ctx context.Context, kindCluster *v1beta1.KindCluster) (string, error) {
+	clusterName := ClusterName(kindCluster)
+
+	allNodes, err := k.listNodes(clusterName)
+	if err != nil {
+		return "", err
+	}
+	controlPlane, err := bootstrapControlPlaneNode(clusterName, allNodes)
+	if err != nil {
+		return "", err
+	}
+	workers, err := k.Workers(ctx, kindCluster)
+	if err != nil {
+		return "", err
+	}
+	index := poolWorkerCount(kindCluster) + 1
+	if len(workers) > 0 {
+		index = workerIndex(clusterName, workers[len(workers)-1]) + 1
+	}
+	name := workerName(clusterName, index)
+
+	// new workers run the image the cluster is running, the one of the control plane if unknown
+	image := kindCluster.Status.Image
+	if image == "" {
+		if image, _, err = k.inspectNode(ctx, controlPlane.String()); err != nil {
+			return "", err
+		}
+	}
+
+	if err := k.addWorker(ctx, kindCluster, controlPlane, name, image, nil, nil); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// addWorker creates the worker node container with the given name, image and container labels and joins it to the
+// kind cluster, configured as the nodes of the given node pool if not nil
+func (k *KindLibHelper) addWorker(ctx context.Context, kindCluster *v1beta1.KindCluster, controlPlane nodes.Node, name string, image string, pool *v1beta1.NodePool, labels map[string]string) error {
+	logger := log.FromContext(ctx)
+	clusterName := ClusterName(kindCluster)
+
+	// new workers are attached to the network of the control plane
+	_, network, err := k.inspectNode(ctx, controlPlane.String())
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Adding worker node", "cluster", clusterName, "node", name, "image", image)
+	args := []string{
+		"run", "--name", name, "--hostname", name,
+		"--detach", "--tty",
+		"--label", fmt.Sprintf("%s=%s", clusterLabelKey, clusterName),
+		"--label", fmt.Sprintf("%s=%s", nodeRoleLabelKey, constants.WorkerNodeRoleValue),
+		"--net", network,
+		"--restart=on-failure:1",
+		"--init=false",
+		"--privileged",
+		"--security-opt", "seccomp=unconfined",
+		"--security-opt", "apparmor=unconfined",
+		"--tmpfs", "/tmp",
+		"--tmpfs", "/run",
+		"--volume", "/var",
+		"--volume", "/lib/modules:/lib/modules:ro",
+		"-e", "KIND_EXPERIMENTAL_CONTAINERD_SNAPSHOTTER",
+	}
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	for _, pair := range pairs {
+		args = append(args, "--label", pair)
+	}
+	if ipv6Enabled(kindCluster) {
+		args = append(args, "--sysctl=net.ipv6.conf.all.disable_ipv6=0", "--sysctl=net.ipv6.conf.all.forwarding=1")
+	}
+	args = append(args, nodePoolRunArgs(kindCluster, pool)...)
+	args = append(args, image)
+	if err := exec.CommandContext(ctx, k.runtime, args...).Run(); err != nil {
+		return errors.Wrapf(err, "failed to create node container %s", name)
+	}
+
+	if err := k.joinWorker(ctx, kindCluster, controlPlane, name, pool); err != nil {
+		// do not leave a half-made node behind, the next attempt starts from scratch
+		if rmErr := k.removeContainer(ctx, name); rmErr != nil {
+			logger.Error(rmErr, "failed to remove node container", "node", name)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// AddWorkerNode creates the worker node container of the given KindMachine with the given name and joins it to the
+// kind cluster. The node runs the given image, the one the cluster is running if empty. The container is labelled
+// with the UID of the KindMachine, so that it is never taken for a worker of spec.workerCount.
+func (k *KindLibHelper) AddWorkerNode(ctx context.Context, kindCluster *v1beta1.KindCluster, kindMachine *v1beta1.KindMachine, name string, image string) error {
+	clusterName := ClusterName(kindCluster)
+	allNodes, err := k.listNodes(clusterName)
+	if err != nil {
+		return err
+	}
+	controlPlane, err := bootstrapControlPlaneNode(clusterName, allNodes)
+	if err != nil {
+		return err
+	}
+
+	if image == "" {
+		image = kindCluster.Status.Image
+	}
+	if image == "" {
+		if image, _, err = k.inspectNode(ctx, controlPlane.String()); err != nil {
+			return err
+		}
+	}
+	return k.addWorker(ctx, kindCluster, controlPlane, name, image, nil, map[string]string{v1beta1.KindMachineUIDLabel: string(kindMachine.UID)})
+}
+
+// NodeExists reports whether a node container with the given name exists in the kind cluster
+func (k *KindLibHelper) NodeExists(ctx context.Context, kindCluster *v1beta1.KindCluster, name string) (bool, error) {
+	node, err := k.node(kindCluster, name)
+	if err != nil {
+		return false, err
+	}
+	return node != nil, nil
+}
+
+// NodeAddresses returns the addresses of the given node of the kind cluster
+func (k *KindLibHelper) NodeAddresses(ctx context.Context, kindCluster *v1beta1.KindCluster, name string) ([]clusterv1.MachineAddress, error) {
+	node, err := k.node(kindCluster, name)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, errors.Errorf("node container %s not found", name)
+	}
+
+	ipv4, ipv6, err := node.IP()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get IP of node %s", name)
+	}
+	addresses := []clusterv1.MachineAddress{{Type: clusterv1.MachineHostName, Address: name}}
+	for _, ip := range []string{ipv4, ipv6} {
+		if ip != "" {
+			addresses = append(addresses, clusterv1.MachineAddress{Type: clusterv1.MachineInternalIP, Address: ip})
+		}
+	}
+	return addresses, nil
+}
+
+// ProviderID returns the provider ID of the given node of the kind cluster, as set by kind on its nodes
+func ProviderID(kindCluster *v1beta1.KindCluster, name string) string {
+	return fmt.Sprintf("kind://%s/%s/%s", kindCluster.Status.Runtime, ClusterName(kindCluster), name)
+}
+
+// RemoveWorker cordons, drains and deletes the given worker node, then removes its container
+func (k *KindLibHelper) RemoveWorker(ctx context.Context, kindCluster *v1beta1.KindCluster, name string) error {
+	logger := log.FromContext(ctx)
+	clusterName := ClusterName(kindCluster)
+
+	allNodes, err := k.listNodes(clusterName)
+	if err != nil {
+		return err
+	}
+	controlPlane, err := bootstrapControlPlaneNode(clusterName, allNodes)
+	if err != nil {
+		return err
+	}
+
+	registered, err := exec.OutputLines(kubectl(ctx, controlPlane, "get", "node", name, "--ignore-not-found", "--output", "name"))
+	if err != nil {
+		return errors.Wrapf(err, "failed to get node %s", name)
+	}
+	if len(registered) > 0 {
+		logger.Info("Draining worker node", "cluster", clusterName, "node", name)
+		if err := kubectl(ctx, controlPlane, "cordon", name).Run(); err != nil {
+			return errors.Wrapf(err, "failed to cordon node %s", name)
+		}
+		if err := kubectl(ctx, controlPlane, "drain", name, "--ignore-daemonsets", "--delete-emptydir-data", "--force", "--timeout=120s").Run(); err != nil {
+			return errors.Wrapf(err, "failed to drain node %s", name)
+		}
+		if err := kubectl(ctx, controlPlane, "delete", "node", name, "--ignore-not-found").Run(); err != nil {
+			return errors.Wrapf(err, "failed to delete node %s", name)
+		}
+	}
+
+	logger.Info("Removing worker node", "cluster", clusterName, "node", name)
+	return k.removeContainer(ctx, name)
+}
+
+// node returns the node of the kind cluster with the given name, nil if not found
+func (k *KindLibHelper) node(kindCluster *v1beta1.KindCluster, name string) (nodes.Node, error) {
+	allNodes, err := k.listNodes(ClusterName(kindCluster))
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range allNodes {
+		if n.String() == name {
+			return n, nil
+		}
+	}
+	return nil, nil
+}
+
+// joinWorker waits for the node container to be up and joins it to the cluster with kubeadm
+func (k *KindLibHelper) joinWorker(ctx context.Context, kindCluster *v1beta1.KindCluster, controlPlane nodes.Node, name string, pool *v1beta1.NodePool) error {
+	node, err := k.node(kindCluster, name)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return errors.Errorf("node container %s not found", name)
+	}
+
+	if err := waitForContainerd(ctx, node); err != nil {
+		return err
+	}
+	if len(kindCluster.Spec.ContainerdConfigPatches) > 0 || len(kindCluster.Spec.ContainerdConfigPatchesJSON6902) > 0 || kindCluster.Spec.Registry != nil {
+		if err := copyContainerdConfig(ctx, controlPlane, node); err != nil {
+			return err
+		}
+	}
+
+	config, err := joinConfiguration(ctx, kindCluster, controlPlane, node, pool)
+	if err != nil {
+		return err
+	}
+	if err := nodeutils.WriteFile(node, kubeadmConfigPath, config); err != nil {
+		return errors.Wrap(err, "failed to write kubeadm config")
+	}
+
+	if err := node.CommandContext(ctx, "kubeadm", "join", "--config", kubeadmConfigPath, "--skip-phases=preflight", "--v=6").Run(); err != nil {
+		return errors.Wrapf(err, "failed to join node %s", name)
+	}
+	return nil
+}
+
+var joinConfigurationTemplate = template.Must(template.New("join").Parse(`apiVersion: {{ .APIVersion }}
+kind: JoinConfiguration
+nodeRegistration:
+  criSocket: "unix:///run/containerd/containerd.sock"
+  kubeletExtraArgs:
+    node-ip: "{{ .NodeAddress }}"
+    provider-id: "{{ .ProviderID }}"
+    node-labels: "{{ .NodeLabels }}"
+discovery:
+  bootstrapToken:
+    apiServerEndpoint: "{{ .ControlPlaneEndpoint }}"
+    token: "{{ .Token }}"
+    caCertHashes:
+    - "{{ .CACertHash }}"
+`))
+
+// joinConfiguration renders a kubeadm JoinConfiguration for the node, with a fresh bootstrap token
+// created on the control plane (the one generated by kind expires after 24h).
+// The kubeadm config patches of the cluster are applied, then labels, taints and patches of the node pool if not nil.
+func joinConfiguration(ctx context.Context, kindCluster *v1beta1.KindCluster, controlPlane nodes.Node, node nodes.Node, pool *v1beta1.NodePool) (string, error) {
+	// kubeadm join <endpoint> --token <token> --discovery-token-ca-cert-hash <hash>
+	lines, err := exec.OutputLines(controlPlane.CommandContext(ctx, "kubeadm", "token", "create", "--ttl", "15m", "--print-join-command"))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create bootstrap token")
+	}
+	if len(lines) == 0 {
+		return "", errors.New("failed to create bootstrap token: no join command")
+	}
+	fields := strings.Fields(lines[len(lines)-1])
+	data := map[string]string{
+		"ProviderID": ProviderID(kindCluster, node.String()),
+		"NodeLabels": nodePoolLabels(pool, map[string]string{v1beta1.KindClusterUIDLabel: string(kindCluster.UID)}),
+	}
+	for i, f := range fields {
+		switch {
+		case i == 2:
+			data["ControlPlaneEndpoint"] = f
+		case f == "--token" && i+1 < len(fields):
+			data["Token"] = fields[i+1]
+		case f == "--discovery-token-ca-cert-hash" && i+1 < len(fields):
+			data["CACertHash"] = fields[i+1]
+		}
+	}
+	if data["ControlPlaneEndpoint"] == "" || data["Token"] == "" || data["CACertHash"] == "" {
+		return "", errors.Errorf("unexpected join command %q", lines[len(lines)-1])
+	}
+
+	ipv4, ipv6, err := node.IP()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get node IP")
+	}
+	data["NodeAddress"] = nodeIP(kindCluster, ipv4, ipv6)
+
+	// kubeadm v1beta3 API is available since Kubernetes 1.22
+	rawVersion, err := nodeutils.KubeVersion(controlPlane)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get Kubernetes version from node")
+	}
+	kubeVersion, err := version.ParseSemantic(rawVersion)
+	if err != nil {
+		return "", errors.Wrap(err, "could not parse Kubernetes version")
+	}
+	data["APIVersion"] = "kubeadm.k8s.io/v1beta3"
+	if kubeVersion.LessThan(version.MustParseSemantic("v1.22.0")) {
+		data["APIVersion"] = "kubeadm.k8s.io/v1beta2"
+	}
+
+	var buf bytes.Buffer
+	if err := joinConfigurationTemplate.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	patches, patches6902 := joinConfigurationPatches(kindCluster, pool)
+	if len(patches) == 0 && len(patches6902) == 0 {
+		return buf.String(), nil
+	}
+	return patchKubeadmConfig(buf.String(), patches, patches6902)
+}
+
+// waitForContainerd waits for the container runtime inside the node to be active
+func waitForContainerd(ctx context.Context, node nodes.Node) error {
+	deadline := time.Now().Add(nodeStartTimeout)
+	for time.Now().Before(deadline) {
+		if err := node.CommandContext(ctx, "systemctl", "is-active", "--quiet", "containerd").Run(); err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+	return errors.Errorf("timed out waiting for node %s to start", node.String())
+}
+
+// inspectNode returns the image and the network of a node container
+func (k *KindLibHelper) inspectNode(ctx context.Context, name string) (image string, network string, err error) {
+	format := `{{.Config.Image}} {{range $k, $v := .NetworkSettings.Networks}}{{$k}} {{end}}`
+	lines, err := exec.OutputLines(exec.CommandContext(ctx, k.runtime, "inspect", "--format", format, name))
+	if err != nil {
+		return "", "", errors.Wrapf(err, "failed to inspect node container %s", name)
+	}
+	fields := strings.Fields(strings.Join(lines, " "))
+	if len(fields) < 2 {
+		return "", "", errors.Errorf("unexpected inspect output for node container %s: %q", name, lines)
+	}
+	return fields[0], fields[1], nil
+}
+
+// removeContainer forcibly removes a node container and its volumes
+func (k *KindLibHelper) removeContainer(ctx context.Context, name string) error {
+	if err := exec.CommandContext(ctx, k.runtime, "rm", "--force", "--volumes", name).Run(); err != nil {
+		return errors.Wrapf(err, "failed to remove node container %s", name)
+	}
+	return nil
+}
+
+// kubectl returns a kubectl command run on the given control plane node
+func kubectl(ctx context.Context, controlPlane nodes.Node, args ...string) exec.Cmd {
+	return controlPlane.CommandContext(ctx, "kubectl", append([]string{"--kubeconfig=/etc/kubernetes/admin.conf"}, args...)...)
+}
+
+// workerName returns the name kind gives to the worker with the given index (<cluster>-worker, <cluster>-worker2, ...)
+func workerName(clusterName string, index int) string {
+	if index <= 1 {
+		return clusterName + "-worker"
+	}
+	return fmt.Sprintf("%s-worker%d", clusterName, index)
+}
+
+// workerIndex is the inverse of workerName
+func workerIndex(clusterName string, name string) int {
+	suffix := strings.TrimPrefix(name, clusterName+"-worker")
+	if suffix == "" {
+		return 1
+	}
+	index, err := strconv.Atoi(suffix)
+	if err != nil {
+		return 0
+	}
+	return index
+}
@@ -0,0 +1,32 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSelectWorkers(t *testing.T) {
+	g := NewWithT(t)
+
+	// the node of a KindMachine named worker2 is labelled, the one of an older KindMachine is not named as a worker
+	workers := []string{"dev-worker3", "dev-worker2", "dev-machine-md-0", "dev-worker", "dev-md-1"}
+	machines := map[string]bool{"dev-worker2": true, "dev-machine-md-0": true}
+	g.Expect(selectWorkers("dev", workers, machines)).To(Equal([]string{"dev-worker", "dev-worker3"}))
+}
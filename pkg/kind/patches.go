@@ -0,0 +1,161 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	v1alpha4Kind "sigs.k8s.io/kind/pkg/apis/config/v1alpha4"
+	"sigs.k8s.io/kind/pkg/cluster/nodes"
+	"sigs.k8s.io/kind/pkg/cluster/nodeutils"
+	"sigs.k8s.io/yaml"
+)
+
+// containerd configuration file inside the node containers
+const containerdConfigPath = "/etc/containerd/config.toml"
+
+// kindPatchesJSON6902 converts the kubeadm configuration JSON 6902 patches to the kind configuration
+func kindPatchesJSON6902(patches []v1beta1.PatchJSON6902) []v1alpha4Kind.PatchJSON6902 {
+	kindPatches := make([]v1alpha4Kind.PatchJSON6902, 0, len(patches))
+	for _, p := range patches {
+		kindPatches = append(kindPatches, v1alpha4Kind.PatchJSON6902{Group: p.Group, Version: p.Version, Kind: p.Kind, Patch: p.Patch})
+	}
+	return kindPatches
+}
+
+// taintsPatch returns a kubeadm configuration merge patch setting the taints of the node.
+// The patch is written in JSON, a subset of YAML.
+func taintsPatch(kind string, taints []corev1.Taint) string {
+	// a Taint always marshals
+	patch, _ := json.Marshal(map[string]interface{}{
+		"kind":             kind,
+		"nodeRegistration": map[string]interface{}{"taints": taints},
+	})
+	return string(patch)
+}
+
+// joinConfigurationPatches returns the kubeadm configuration patches of a node joined by the controller:
+// the patches of the cluster, then the ones of its node pool if not nil
+func joinConfigurationPatches(kindCluster *v1beta1.KindCluster, pool *v1beta1.NodePool) ([]string, []v1beta1.PatchJSON6902) {
+	patches := append([]string{}, kindCluster.Spec.KubeadmConfigPatches...)
+	patches6902 := append([]v1beta1.PatchJSON6902{}, kindCluster.Spec.KubeadmConfigPatchesJSON6902...)
+	if pool != nil {
+		patches = append(patches, pool.KubeadmConfigPatches...)
+		if len(pool.Taints) > 0 {
+			patches = append(patches, taintsPatch("JoinConfiguration", pool.Taints))
+		}
+		patches6902 = append(patches6902, pool.KubeadmConfigPatchesJSON6902...)
+	}
+	return patches, patches6902
+}
+
+// patchKubeadmConfig applies the kubeadm configuration patches to the given configuration object,
+// matching them on its kind and apiVersion as kind does
+func patchKubeadmConfig(config string, patches []string, patches6902 []v1beta1.PatchJSON6902) (string, error) {
+	var meta struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+	}
+	if err := yaml.Unmarshal([]byte(config), &meta); err != nil {
+		return "", errors.Wrap(err, "failed to parse kubeadm config")
+	}
+	doc, err := yaml.YAMLToJSON([]byte(config))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse kubeadm config")
+	}
+
+	for _, raw := range patches {
+		reader := utilyaml.NewYAMLReader(bufio.NewReader(strings.NewReader(raw)))
+		for {
+			p, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", classify(ErrInvalidConfig, errors.Wrap(err, "failed to parse kubeadm config patch"))
+			}
+			if strings.TrimSpace(string(p)) == "" {
+				continue
+			}
+			var match struct {
+				APIVersion string `json:"apiVersion"`
+				Kind       string `json:"kind"`
+			}
+			if err := yaml.Unmarshal(p, &match); err != nil {
+				return "", classify(ErrInvalidConfig, errors.Wrap(err, "failed to parse kubeadm config patch"))
+			}
+			if match.Kind != meta.Kind || (match.APIVersion != "" && match.APIVersion != meta.APIVersion) {
+				continue
+			}
+			patch, err := yaml.YAMLToJSON(p)
+			if err != nil {
+				return "", classify(ErrInvalidConfig, errors.Wrap(err, "failed to parse kubeadm config patch"))
+			}
+			if doc, err = jsonpatch.MergePatch(doc, patch); err != nil {
+				return "", classify(ErrInvalidConfig, errors.Wrap(err, "failed to apply kubeadm config patch"))
+			}
+		}
+	}
+
+	for _, p := range patches6902 {
+		apiVersion := p.Version
+		if p.Group != "" {
+			apiVersion = p.Group + "/" + p.Version
+		}
+		if p.Kind != meta.Kind || apiVersion != meta.APIVersion {
+			continue
+		}
+		raw, err := yaml.YAMLToJSON([]byte(p.Patch))
+		if err != nil {
+			return "", classify(ErrInvalidConfig, errors.Wrap(err, "failed to parse kubeadm config JSON 6902 patch"))
+		}
+		patch, err := jsonpatch.DecodePatch(raw)
+		if err != nil {
+			return "", classify(ErrInvalidConfig, errors.Wrap(err, "failed to parse kubeadm config JSON 6902 patch"))
+		}
+		if doc, err = patch.Apply(doc); err != nil {
+			return "", classify(ErrInvalidConfig, errors.Wrap(err, "failed to apply kubeadm config JSON 6902 patch"))
+		}
+	}
+
+	patched, err := yaml.JSONToYAML(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(patched), nil
+}
+
+// copyContainerdConfig copies the containerd configuration of a node created by kind, where the containerd config patches
+// of the cluster have been applied, to a node joined by the controller and restarts its containerd
+func copyContainerdConfig(ctx context.Context, from nodes.Node, to nodes.Node) error {
+	if err := nodeutils.CopyNodeToNode(from, to, containerdConfigPath); err != nil {
+		return errors.Wrapf(err, "failed to copy containerd config to node %s", to.String())
+	}
+	if err := to.CommandContext(ctx, "systemctl", "restart", "containerd").Run(); err != nil {
+		return errors.Wrapf(err, "failed to restart containerd on node %s", to.String())
+	}
+	return waitForContainerd(ctx, to)
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/kind/pkg/cluster/nodes"
+	"sigs.k8s.io/kind/pkg/cluster/nodeutils"
+	"sigs.k8s.io/kind/pkg/exec"
+)
+
+// PreloadImages loads the images of spec.preloadImages into the nodes of the kind cluster missing them,
+// the same way "kind load" does, and returns their state
+func (k *KindLibHelper) PreloadImages(ctx context.Context, kindCluster *v1beta1.KindCluster) ([]v1beta1.PreloadedImage, error) {
+	allNodes, err := k.Provider.ListInternalNodes(ClusterName(kindCluster))
+	if err != nil {
+		return nil, classify(ErrRuntimeUnavailable, err)
+	}
+
+	dir, err := os.MkdirTemp("", "kind-preload-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	images := make([]v1beta1.PreloadedImage, 0, len(kindCluster.Spec.PreloadImages))
+	for i, image := range kindCluster.Spec.PreloadImages {
+		images = append(images, k.preloadImage(ctx, allNodes, image, filepath.Join(dir, fmt.Sprintf("image-%d.tar", i))))
+	}
+	return images, nil
+}
+
+// preloadImage loads the given image reference or image archive into the nodes missing it,
+// saving images of the docker host to archive
+func (k *KindLibHelper) preloadImage(ctx context.Context, allNodes []nodes.Node, image string, archive string) v1beta1.PreloadedImage {
+	logger := log.FromContext(ctx)
+	status := v1beta1.PreloadedImage{Image: image, State: v1beta1.PreloadedImageLoaded}
+
+	// image names and ID expected on the nodes, the ID is only known for images of the docker host
+	var names []string
+	id := ""
+	if filepath.IsAbs(image) {
+		if _, err := os.Stat(image); err != nil {
+			status.State, status.Message = v1beta1.PreloadedImageMissing, err.Error()
+			return status
+		}
+		tags, err := archiveImages(image)
+		if err != nil {
+			status.State, status.Message = v1beta1.PreloadedImageFailed, err.Error()
+			return status
+		}
+		names, archive = tags, image
+	} else {
+		lines, err := exec.OutputLines(exec.CommandContext(ctx, k.runtime, "image", "inspect", "--format", "{{.Id}}", image))
+		if err != nil || len(lines) != 1 {
+			status.State, status.Message = v1beta1.PreloadedImageMissing, "image not found on the docker host"
+			return status
+		}
+		names, id = []string{image}, strings.TrimSpace(lines[0])
+	}
+
+	var missing []nodes.Node
+	for _, n := range allNodes {
+		if !hasImages(n, names, id) {
+			missing = append(missing, n)
+		}
+	}
+	if len(missing) == 0 {
+		return status
+	}
+
+	if id != "" {
+		if err := exec.CommandContext(ctx, k.runtime, "save", "--output", archive, image).Run(); err != nil {
+			status.State, status.Message = v1beta1.PreloadedImageFailed, errors.Wrapf(err, "failed to save image %s", image).Error()
+			return status
+		}
+	}
+	for _, n := range missing {
+		logger.Info("Loading image into node", "image", image, "node", n.String())
+		if err := loadImageArchive(n, archive); err != nil {
+			status.State, status.Message = v1beta1.PreloadedImageFailed, errors.Wrapf(err, "failed to load image into node %s", n.String()).Error()
+			return status
+		}
+	}
+	return status
+}
+
+// hasImages reports whether the node has all the given images, with the given ID if not empty.
+// Untagged images cannot be looked up, they are always reported missing.
+func hasImages(node nodes.Node, names []string, id string) bool {
+	if len(names) == 0 {
+		return false
+	}
+	for _, name := range names {
+		nodeID, err := nodeutils.ImageID(node, name)
+		if err != nil || (id != "" && nodeID != id) {
+			return false
+		}
+	}
+	return true
+}
+
+// loadImageArchive imports the image archive into the containerd of the node
+func loadImageArchive(node nodes.Node, archive string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return nodeutils.LoadImageArchive(node, f)
+}
+
+// archiveImages returns the image names recorded in the manifest of an image archive
+func archiveImages(archive string) ([]string, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, errors.Errorf("no manifest.json in image archive %s", archive)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read image archive %s", archive)
+		}
+		if hdr.Name != "manifest.json" {
+			continue
+		}
+		var manifest []struct {
+			RepoTags []string `json:"RepoTags"`
+		}
+		if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+			return nil, errors.Wrapf(err, "invalid manifest.json in image archive %s", archive)
+		}
+		var names []string
+		for _, m := range manifest {
+			names = append(names, m.RepoTags...)
+		}
+		return names, nil
+	}
+}
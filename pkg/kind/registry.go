@@ -0,0 +1,193 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/kind/pkg/exec"
+)
+
+// The local registry is a registry container started on the docker network of the nodes once the kind cluster
+// exists, see https://kind.sigs.k8s.io/docs/user/local-registry/. It lives as long as the KindCluster, so that the
+// images pushed to it survive the kind cluster being recreated.
+
+// port the registry listens on in its container
+const registryPort = 5000
+
+// registryHostingTemplate is the ConfigMap documenting the local registry in the kind cluster, see
+// https://github.com/kubernetes/enhancements/tree/master/keps/sig-cluster-lifecycle/generic/1755-communicating-a-local-registry
+const registryHostingTemplate = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: local-registry-hosting
+  namespace: kube-public
+data:
+  localRegistryHosting.v1: |
+    host: "%s"
+    hostFromContainerRuntime: "%s"
+    help: "https://kind.sigs.k8s.io/docs/user/local-registry/"
+`
+
+// registryHost returns the name of the registry for the docker host, localhost:<hostPort>
+func registryHost(kindCluster *v1beta1.KindCluster) string {
+	return fmt.Sprintf("localhost:%d", kindCluster.Spec.Registry.HostPort)
+}
+
+// registryContainerdPatch returns the containerd configuration patch making the nodes pull the images of the
+// registry host from the registry container
+func registryContainerdPatch(kindCluster *v1beta1.KindCluster) string {
+	return fmt.Sprintf(`[plugins."io.containerd.grpc.v1.cri".registry.mirrors.%q]
+  endpoint = ["http://%s:%d"]`, registryHost(kindCluster), RegistryName(kindCluster), registryPort)
+}
+
+// registryContainer is the state of a registry container
+type registryContainer struct {
+	running  bool
+	owner    string
+	networks []string
+}
+
+// inspectRegistry returns the state of the given registry container, nil if it does not exist
+func (k *KindLibHelper) inspectRegistry(ctx context.Context, name string) (*registryContainer, error) {
+	// the name filters of docker, podman and nerdctl differ, look for the container in the full list
+	lines, err := exec.OutputLines(exec.CommandContext(ctx, k.runtime, "ps", "--all", "--format", "{{.Names}}"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list registry container %s", name)
+	}
+	found := false
+	for _, line := range lines {
+		found = found || strings.TrimSpace(line) == name
+	}
+	if !found {
+		return nil, nil
+	}
+
+	format := fmt.Sprintf(`{{.State.Running}};{{index .Config.Labels %q}};{{range $k, $v := .NetworkSettings.Networks}}{{$k}} {{end}}`, v1beta1.KindClusterUIDLabel)
+	lines, err = exec.OutputLines(exec.CommandContext(ctx, k.runtime, "inspect", "--format", format, name))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to inspect registry container %s", name)
+	}
+	fields := strings.SplitN(strings.Join(lines, " "), ";", 3)
+	if len(fields) != 3 {
+		return nil, errors.Errorf("unexpected inspect output for registry container %s: %q", name, lines)
+	}
+	return &registryContainer{
+		running:  fields[0] == "true",
+		owner:    fields[1],
+		networks: strings.Fields(fields[2]),
+	}, nil
+}
+
+// ReconcileRegistry makes sure the local registry of the kind cluster runs on the network of its nodes and is
+// published in the local-registry-hosting ConfigMap of the kind cluster
+func (k *KindLibHelper) ReconcileRegistry(ctx context.Context, kindCluster *v1beta1.KindCluster) error {
+	logger := log.FromContext(ctx)
+	clusterName := ClusterName(kindCluster)
+	name := RegistryName(kindCluster)
+
+	allNodes, err := k.listNodes(clusterName)
+	if err != nil {
+		return err
+	}
+	controlPlane, err := bootstrapControlPlaneNode(clusterName, allNodes)
+	if err != nil {
+		return err
+	}
+	_, network, err := k.inspectNode(ctx, controlPlane.String())
+	if err != nil {
+		return err
+	}
+
+	registry, err := k.inspectRegistry(ctx, name)
+	if err != nil {
+		return err
+	}
+	if registry == nil {
+		logger.Info("Starting local registry", "cluster", clusterName, "registry", name, "host", registryHost(kindCluster))
+		args := []string{
+			"run", "--detach",
+			"--restart=always",
+			"--name", name,
+			"--net", network,
+			"--label", fmt.Sprintf("%s=%s", v1beta1.KindClusterUIDLabel, kindCluster.UID),
+			"--publish", fmt.Sprintf("127.0.0.1:%d:%d", kindCluster.Spec.Registry.HostPort, registryPort),
+			kindCluster.Spec.Registry.Image,
+		}
+		if err := exec.CommandContext(ctx, k.runtime, args...).Run(); err != nil {
+			return errors.Wrapf(err, "failed to start registry container %s", name)
+		}
+	} else {
+		if registry.owner != string(kindCluster.UID) {
+			return errors.Errorf("container %s already exists and is not owned by this KindCluster", name)
+		}
+		if !registry.running {
+			logger.Info("Restarting local registry", "cluster", clusterName, "registry", name)
+			if err := exec.CommandContext(ctx, k.runtime, "start", name).Run(); err != nil {
+				return errors.Wrapf(err, "failed to start registry container %s", name)
+			}
+		}
+		// the registry outlives the kind cluster, reconnect it when the cluster has been recreated
+		connected := false
+		for _, n := range registry.networks {
+			connected = connected || n == network
+		}
+		if !connected {
+			logger.Info("Connecting local registry to the kind network", "cluster", clusterName, "registry", name, "network", network)
+			if err := exec.CommandContext(ctx, k.runtime, "network", "connect", network, name).Run(); err != nil {
+				return errors.Wrapf(err, "failed to connect registry container %s to network %s", name, network)
+			}
+		}
+	}
+
+	manifest := fmt.Sprintf(registryHostingTemplate, registryHost(kindCluster), fmt.Sprintf("%s:%d", name, registryPort))
+	cmd := kubectl(ctx, controlPlane, "apply", "--filename", "-")
+	cmd.SetStdin(strings.NewReader(manifest))
+	if err := cmd.Run(); err != nil {
+		return errors.Wrap(err, "failed to publish the local-registry-hosting ConfigMap")
+	}
+	return nil
+}
+
+// DeleteRegistry removes the local registry container of the kind cluster, unless it is not owned by the KindCluster
+func (k *KindLibHelper) DeleteRegistry(ctx context.Context, kindCluster *v1beta1.KindCluster) error {
+	logger := log.FromContext(ctx)
+	name := RegistryName(kindCluster)
+
+	registry, err := k.inspectRegistry(ctx, name)
+	if err != nil {
+		return err
+	}
+	if registry == nil {
+		return nil
+	}
+	if registry.owner != string(kindCluster.UID) {
+		logger.Info("Local registry is not owned by this KindCluster, leaving it untouched", "registry", name)
+		return nil
+	}
+
+	logger.Info("Deleting local registry", "registry", name)
+	if err := exec.CommandContext(ctx, k.runtime, "rm", "--force", "--volumes", name).Run(); err != nil {
+		return errors.Wrapf(err, "failed to remove registry container %s", name)
+	}
+	return nil
+}
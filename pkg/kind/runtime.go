@@ -0,0 +1,90 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"context"
+
+	"github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/pkg/errors"
+	kindApiCluster "sigs.k8s.io/kind/pkg/cluster"
+	"sigs.k8s.io/kind/pkg/exec"
+)
+
+// Runtimes are the container runtimes supported, in the order kind detects them
+var Runtimes = []v1beta1.ContainerRuntime{v1beta1.DockerRuntime, v1beta1.PodmanRuntime, v1beta1.NerdctlRuntime}
+
+// RuntimeAvailable reports whether the CLI of the container runtime is installed and can reach the runtime
+func RuntimeAvailable(ctx context.Context, runtime v1beta1.ContainerRuntime) bool {
+	return cliAvailable(ctx, string(runtime))
+}
+
+// cliAvailable reports whether the CLI of a container runtime, see Host.cli, can reach its runtime
+func cliAvailable(ctx context.Context, cli string) bool {
+	return exec.CommandContext(ctx, cli, "version").Run() == nil
+}
+
+// DetectRuntime returns the first available container runtime, see Runtimes
+func DetectRuntime(ctx context.Context) (v1beta1.ContainerRuntime, error) {
+	for _, runtime := range Runtimes {
+		if RuntimeAvailable(ctx, runtime) {
+			return runtime, nil
+		}
+	}
+	return "", classify(ErrRuntimeUnavailable, errors.New("no container runtime available, docker, podman or nerdctl is required"))
+}
+
+// ResolveRuntime returns the container runtime of the given KindCluster, as long as it is available.
+// The runtime recorded in the status wins, so that a kind cluster is always managed with the runtime it has been
+// created with, then spec.runtime, docker on remote hosts, finally defaultRuntime, the controller default,
+// detected when empty. The given container host of the KindCluster must be acquired, see AcquireHost.
+func ResolveRuntime(ctx context.Context, kindCluster *v1beta1.KindCluster, defaultRuntime v1beta1.ContainerRuntime, host *Host) (v1beta1.ContainerRuntime, error) {
+	runtime := kindCluster.Status.Runtime
+	if runtime == "" {
+		runtime = kindCluster.Spec.Runtime
+	}
+	if runtime == "" && (kindCluster.Status.Host != "" || kindCluster.Spec.HostRef != nil) {
+		runtime = v1beta1.DockerRuntime
+	}
+	if runtime == "" {
+		runtime = defaultRuntime
+	}
+	if runtime == "" {
+		return DetectRuntime(ctx)
+	}
+	if !cliAvailable(ctx, host.cli(runtime)) {
+		return "", classify(ErrRuntimeUnavailable, errors.Errorf("container runtime %s is not available", runtime))
+	}
+	return runtime, nil
+}
+
+// providerOption returns the kind provider option of the given container runtime on the given host.
+// The docker provider of kind runs the docker CLI of the controller, remote hosts have their own docker CLI:
+// kind runs it with its nerdctl provider, the only one taking the CLI to run, nerdctl being compatible with docker.
+func providerOption(runtime v1beta1.ContainerRuntime, host *Host) kindApiCluster.ProviderOption {
+	if host != nil {
+		return kindApiCluster.ProviderWithNerdctl(host.cli(runtime))
+	}
+	switch runtime {
+	case v1beta1.PodmanRuntime:
+		return kindApiCluster.ProviderWithPodman()
+	case v1beta1.NerdctlRuntime:
+		return kindApiCluster.ProviderWithNerdctl(string(runtime))
+	default:
+		return kindApiCluster.ProviderWithDocker()
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kind
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mbovo/cluster-api-provider-kind/api/v1beta1"
+	"github.com/mbovo/cluster-api-provider-kind/pkg/nodeimage"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/kind/pkg/cluster/constants"
+	"sigs.k8s.io/kind/pkg/cluster/nodes"
+	"sigs.k8s.io/kind/pkg/cluster/nodeutils"
+	"sigs.k8s.io/kind/pkg/exec"
+)
+
+// Kubernetes binaries copied from the new node image into the control plane nodes before running kubeadm upgrade
+var upgradeBinaries = []string{"kubeadm", "kubelet", "kubectl"}
+
+// KubeVersion returns the Kubernetes version the kind cluster control plane is running
+func (k *KindLibHelper) KubeVersion(ctx context.Context, kindCluster *v1beta1.KindCluster) (string, error) {
+	clusterName := ClusterName(kindCluster)
+	allNodes, err := k.listNodes(clusterName)
+	if err != nil {
+		return "", err
+	}
+	node, err := bootstrapControlPlaneNode(clusterName, allNodes)
+	if err != nil {
+		return "", err
+	}
+	return nodeutils.KubeVersion(node)
+}
+
+// ImageKubeVersion returns the Kubernetes version shipped in the given node image
+func (k *KindLibHelper) ImageKubeVersion(ctx context.Context, image string) (string, error) {
+	lines, err := exec.OutputLines(exec.CommandContext(ctx, k.runtime, "run", "--rm", "--entrypoint", "cat", image, "/kind/version"))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read Kubernetes version of image %s", image)
+	}
+	if len(lines) != 1 {
+		return "", errors.Errorf("unexpected Kubernetes version of image %s: %q", image, lines)
+	}
+	return strings.TrimSpace(lines[0]), nil
+}
+
+// Upgrade moves the kind cluster to the given node image node by node: the control plane nodes are upgraded in place
+// with kubeadm, the worker nodes are replaced by new containers running the image.
+// Nodes already upgraded are skipped, so that a failed upgrade can be run again. progress is called with the number
+// of nodes running the new image every time a node is upgraded.
+func (k *KindLibHelper) Upgrade(ctx context.Context, kindCluster *v1beta1.KindCluster, image string, progress func(updated int32)) error {
+	logger := log.FromContext(ctx)
+	clusterName := ClusterName(kindCluster)
+
+	targetVersion, err := k.ImageKubeVersion(ctx, image)
+	if err != nil {
+		return err
+	}
+
+	allNodes, err := k.listNodes(clusterName)
+	if err != nil {
+		return err
+	}
+	bootstrap, err := bootstrapControlPlaneNode(clusterName, allNodes)
+	if err != nil {
+		return err
+	}
+	controlPlanes, err := nodeutils.SelectNodesByRole(allNodes, constants.ControlPlaneNodeRoleValue)
+	if err != nil {
+		return err
+	}
+	workers, err := k.workerNodes(ctx, kindCluster)
+	if err != nil {
+		return err
+	}
+
+	updated := int32(0)
+	progress(updated)
+
+	// the bootstrap control plane runs "kubeadm upgrade apply", the other ones follow with "kubeadm upgrade node"
+	ordered := []nodes.Node{bootstrap}
+	for _, n := range controlPlanes {
+		if n.String() != bootstrap.String() {
+			ordered = append(ordered, n)
+		}
+	}
+	for i, node := range ordered {
+		current, err := nodeutils.KubeVersion(node)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get Kubernetes version of node %s", node.String())
+		}
+		if current != targetVersion {
+			logger.Info("Upgrading control plane node", "cluster", clusterName, "node", node.String(), "from", current, "to", targetVersion)
+			if err := k.copyBinaries(ctx, image, node); err != nil {
+				return err
+			}
+			// the preflight checks and the version skew policy of kubeadm apply, a failed check fails the upgrade
+			upgrade := []string{"upgrade", "node"}
+			if i == 0 {
+				upgrade = []string{"upgrade", "apply", targetVersion, "--yes"}
+			}
+			if err := node.CommandContext(ctx, "kubeadm", upgrade...).Run(); err != nil {
+				return errors.Wrapf(err, "failed to upgrade node %s", node.String())
+			}
+			if err := node.CommandContext(ctx, "systemctl", "restart", "kubelet").Run(); err != nil {
+				return errors.Wrapf(err, "failed to restart kubelet on node %s", node.String())
+			}
+			// kind reads the node version from this file, write it last so that a failed upgrade is run again
+			if err := nodeutils.WriteFile(node, "/kind/version", targetVersion); err != nil {
+				return errors.Wrapf(err, "failed to record Kubernetes version of node %s", node.String())
+			}
+		}
+		updated++
+		progress(updated)
+	}
+
+	for _, name := range workers {
+		current, _, err := k.inspectNode(ctx, name)
+		if err != nil {
+			return err
+		}
+		// workers of a node pool are replaced with the configuration of the pool, keeping the image of the pool if set
+		pool := workerPool(kindCluster, workerIndex(clusterName, name))
+		workerImage := image
+		if pool != nil && pool.Image != "" {
+			workerImage = pool.Image
+		}
+		if !nodeimage.SameImage(current, workerImage) {
+			logger.Info("Replacing worker node", "cluster", clusterName, "node", name, "from", current, "to", workerImage)
+			if err := k.RemoveWorker(ctx, kindCluster, name); err != nil {
+				return err
+			}
+			if err := k.addWorker(ctx, kindCluster, bootstrap, name, workerImage, pool, nil); err != nil {
+				return err
+			}
+		}
+		updated++
+		progress(updated)
+	}
+
+	return nil
+}
+
+// copyBinaries replaces the Kubernetes binaries of the node with the ones shipped in the given image
+func (k *KindLibHelper) copyBinaries(ctx context.Context, image string, node nodes.Node) error {
+	lines, err := exec.OutputLines(exec.CommandContext(ctx, k.runtime, "create", image))
+	if err != nil {
+		return errors.Wrapf(err, "failed to create container from image %s", image)
+	}
+	if len(lines) == 0 {
+		return errors.Errorf("no container created from image %s", image)
+	}
+	source := strings.TrimSpace(lines[len(lines)-1])
+	defer func() {
+		_ = exec.CommandContext(ctx, k.runtime, "rm", "--force", source).Run()
+	}()
+
+	dir, err := os.MkdirTemp("", "kind-upgrade-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	for _, bin := range upgradeBinaries {
+		if err := exec.CommandContext(ctx, k.runtime, "cp", source+":/usr/bin/"+bin, filepath.Join(dir, bin)).Run(); err != nil {
+			return errors.Wrapf(err, "failed to copy %s from image %s", bin, image)
+		}
+		// copy aside and rename, a running binary cannot be overwritten
+		if err := exec.CommandContext(ctx, k.runtime, "cp", filepath.Join(dir, bin), node.String()+":/usr/bin/"+bin+".new").Run(); err != nil {
+			return errors.Wrapf(err, "failed to copy %s to node %s", bin, node.String())
+		}
+		if err := node.CommandContext(ctx, "mv", "-f", "/usr/bin/"+bin+".new", "/usr/bin/"+bin).Run(); err != nil {
+			return errors.Wrapf(err, "failed to replace %s on node %s", bin, node.String())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubeconfig
+
+import "fmt"
+
+// Rewrite renames the cluster, user and context entries generated by kind
+// (kind-<name>) to the names used by Cluster API for the given cluster and,
+// when server is not empty, points the cluster entry to it.
+// Only the first cluster, user and context are kept.
+func Rewrite(cfg *Config, clusterName string, server string) error {
+	if len(cfg.Clusters) == 0 || len(cfg.Users) == 0 {
+		return fmt.Errorf("kubeconfig has no cluster or user entries")
+	}
+
+	userName := fmt.Sprintf("%s-admin", clusterName)
+	contextName := fmt.Sprintf("%s@%s", userName, clusterName)
+
+	cluster := cfg.Clusters[0]
+	cluster.Name = clusterName
+	if server != "" {
+		cluster.Cluster.Server = server
+	}
+	user := cfg.Users[0]
+	user.Name = userName
+
+	cfg.Clusters = []NamedCluster{cluster}
+	cfg.Users = []NamedUser{user}
+	cfg.Contexts = []NamedContext{{
+		Name:    contextName,
+		Context: Context{Cluster: clusterName, User: userName},
+	}}
+	cfg.CurrentContext = contextName
+
+	return nil
+}
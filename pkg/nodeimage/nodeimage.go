@@ -0,0 +1,184 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package nodeimage resolves the kind node images by Kubernetes version.
+package nodeimage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+// DefaultRepository is the repository kind publishes its node images to
+const DefaultRepository = "kindest/node"
+
+// RepositoryKey is the key of the node images override ConfigMap holding the repository
+// replacing DefaultRepository, e.g. a mirror in an air-gapped environment.
+// Every other key of the ConfigMap is a Kubernetes version (e.g. v1.25.3) mapped to a full image reference.
+const RepositoryKey = "repository"
+
+// kindRelease lists the node images published with a kind release, by Kubernetes version
+type kindRelease struct {
+	version string
+	images  map[string]string
+}
+
+// node image digests published in the kind release notes (https://github.com/kubernetes-sigs/kind/releases),
+// most recent release first. The first one is the release of the kind library the provider is built with, whose
+// images are the supported ones; the images of the older releases are kept for the clusters created with them.
+// Only the default image (defaults.Image of the kind library) is listed for v0.20.
+var kindReleases = []kindRelease{
+	{
+		version: "v0.23.0",
+		images: map[string]string{
+			"v1.30.0":  "sha256:047357ac0cfea04663786a612ba1eaba9702bef25227a794b52890dd8bcd692e",
+			"v1.29.4":  "sha256:3abb816a5b1061fb15c6e9e60856ec40d56b7b52bcea5f5f1350bc6e2320b6f8",
+			"v1.28.9":  "sha256:dca54bc6a6079dd34699d53d7d4ffa2e853e46a20cd12d619a09207e35300bd0",
+			"v1.27.13": "sha256:17439fa5b32290e3ead39ead1250dca1d822d94a10d26f1981756cd51b24b9d8",
+			"v1.26.15": "sha256:84333e26cae1d70361bb7339efb568df1871419f2019c80f9a12b7e2d485fe19",
+			"v1.25.16": "sha256:5da57dfc290ac3599e775e63b8b6c49c0c85d3fec771cd7d55b45fae14b38d3b",
+		},
+	},
+	{
+		version: "v0.20.0",
+		images: map[string]string{
+			"v1.27.3": "sha256:3966ac761ae0136263ffdb6cfd4db23ef8a83cba8a463690e98317add2c9ba72",
+		},
+	},
+	{
+		version: "v0.17.0",
+		images: map[string]string{
+			"v1.25.3":  "sha256:f52781bc0d7a19fb6c405c2af83abfeb311f130707a0e219175677e366cc45d1",
+			"v1.24.7":  "sha256:577c630ce8e509131eab1aea12c022190978dd2f745aac5eb1fe65c0807eb315",
+			"v1.23.13": "sha256:ef453bb7c79f0e3caba88d2067d4196f427794cb4a9e4cf2ae0fc1ea1e1b8f3c",
+			"v1.22.15": "sha256:7d9708c4b0873f0fe2e171e2b1b7f45ae89482617778c1c875f1053d4cef2e41",
+			"v1.21.14": "sha256:9d9eb5fb26b4fbc0c6d95fa8c790414f9750dd583f5d7cee45d92e8c26670aa1",
+			"v1.20.15": "sha256:a32bf55309294120616886b5338f95dd98a2f7231519c7dedcec32ba29699394",
+			"v1.19.16": "sha256:476cb3269232888437b61deca013832fee41f9f074f9bed79f57e4280f7c48b7",
+		},
+	},
+	{
+		version: "v0.16.0",
+		images: map[string]string{
+			"v1.25.2":  "sha256:9be91e9e9cdf116809841fc77ebdb8845443c4c72fe5218f3ae9eb57fdb4bace",
+			"v1.24.6":  "sha256:97e8d00bc37a7598a0b32d1fabd155a96355c49fa0d4d4790aab0f161bf31be1",
+			"v1.23.12": "sha256:9402cf1330bbd3a0d097d2033fa489b2abe40d479cc5ef47d0b7a6960613a7e0",
+			"v1.22.15": "sha256:bfd5eaae36849bfb3c1e3b9442f3da17d730718248939d9d547e86bbac5da586",
+			"v1.21.14": "sha256:ad5b7446dd8332439f22a1efdac73670f0da158c00f0a70b45716e7ef3fae20b",
+			"v1.20.15": "sha256:45d0194a8069c46483a0e509088ab9249302af561ebee76a1281a1f08ecb4ed3",
+			"v1.19.16": "sha256:a146f9819fece706b337d34125bbd5cb8ae4d25558427bf2fa3ee8ad231236f2",
+		},
+	},
+}
+
+// Resolve returns the node image of the given Kubernetes version, pinned by digest, in the repository of the given
+// image (DefaultRepository if empty). The image is taken from overrides (may be nil) when present,
+// from the images published by kind otherwise, the ones of the release of the kind library first.
+// Versions kind never published an image for are rejected.
+func Resolve(image string, kubeVersion string, overrides map[string]string) (string, error) {
+	kubeVersion, err := NormalizeKubeVersion(kubeVersion)
+	if err != nil {
+		return "", err
+	}
+	if image, ok := overrides[kubeVersion]; ok {
+		return image, nil
+	}
+
+	repository, _, _ := SplitImage(image)
+	if repository == "" || repository == DefaultRepository {
+		repository = DefaultRepository
+		if mirror, ok := overrides[RepositoryKey]; ok {
+			repository = mirror
+		}
+	}
+
+	for _, release := range kindReleases {
+		if digest, ok := release.images[kubeVersion]; ok {
+			return fmt.Sprintf("%s:%s@%s", repository, kubeVersion, digest), nil
+		}
+	}
+	return "", errors.Errorf("kind does not publish a node image for Kubernetes %s, supported versions are: %s",
+		kubeVersion, strings.Join(SupportedKubeVersions(), ", "))
+}
+
+// NormalizeKubeVersion validates a Kubernetes version (e.g. 1.25.3 or v1.25.3) and returns it prefixed with v
+func NormalizeKubeVersion(kubeVersion string) (string, error) {
+	v, err := version.ParseSemantic(kubeVersion)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid Kubernetes version %q", kubeVersion)
+	}
+	return "v" + v.String(), nil
+}
+
+// SupportedKubeVersions returns the Kubernetes versions kind publishes a node image for, most recent first
+func SupportedKubeVersions() []string {
+	seen := map[string]bool{}
+	versions := []*version.Version{}
+	for _, release := range kindReleases {
+		for v := range release.images {
+			if !seen[v] {
+				seen[v] = true
+				versions = append(versions, version.MustParseSemantic(v))
+			}
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[j].LessThan(versions[i]) })
+
+	supported := make([]string, 0, len(versions))
+	for _, v := range versions {
+		supported = append(supported, "v"+v.String())
+	}
+	return supported
+}
+
+// SplitImage splits an image reference in repository, tag and digest, any of them may be empty.
+// The registry port is part of the repository (e.g. localhost:5000/kindest/node).
+func SplitImage(image string) (repository, tag, digest string) {
+	repository = image
+	if i := strings.Index(repository, "@"); i >= 0 {
+		repository, digest = repository[:i], repository[i+1:]
+	}
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository, tag = repository[:i], repository[i+1:]
+	}
+	return repository, tag, digest
+}
+
+// TagKubeVersion returns the Kubernetes version the node image is tagged with (e.g. v1.25.3),
+// empty if its tag is not a Kubernetes version
+func TagKubeVersion(image string) string {
+	_, tag, _ := SplitImage(image)
+	kubeVersion, err := NormalizeKubeVersion(tag)
+	if err != nil {
+		return ""
+	}
+	return kubeVersion
+}
+
+// SameImage reports whether two image references designate the same image: they are equal, or they only differ
+// because one of them is not pinned by digest
+func SameImage(a, b string) bool {
+	if a == b {
+		return true
+	}
+	repoA, tagA, digestA := SplitImage(a)
+	repoB, tagB, digestB := SplitImage(b)
+	return repoA == repoB && tagA == tagB && (digestA == "" || digestB == "")
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2022 Manuel Bovo.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodeimage
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kind/pkg/apis/config/defaults"
+)
+
+const digest1253 = "sha256:f52781bc0d7a19fb6c405c2af83abfeb311f130707a0e219175677e366cc45d1"
+
+func TestSplitImage(t *testing.T) {
+	tests := []struct {
+		image      string
+		repository string
+		tag        string
+		digest     string
+	}{
+		{image: "kindest/node", repository: "kindest/node"},
+		{image: "kindest/node:v1.25.3", repository: "kindest/node", tag: "v1.25.3"},
+		{image: "kindest/node@" + digest1253, repository: "kindest/node", digest: digest1253},
+		{image: "kindest/node:v1.25.3@" + digest1253, repository: "kindest/node", tag: "v1.25.3", digest: digest1253},
+		{image: "host:5000/kindest/node", repository: "host:5000/kindest/node"},
+		{image: "host:5000/kindest/node:v1.25.3", repository: "host:5000/kindest/node", tag: "v1.25.3"},
+		{image: "host:5000/kindest/node:v1.25.3@" + digest1253, repository: "host:5000/kindest/node", tag: "v1.25.3", digest: digest1253},
+		{image: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			g := NewWithT(t)
+
+			repository, tag, digest := SplitImage(tt.image)
+			g.Expect(repository).To(Equal(tt.repository))
+			g.Expect(tag).To(Equal(tt.tag))
+			g.Expect(digest).To(Equal(tt.digest))
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name        string
+		image       string
+		kubeVersion string
+		overrides   map[string]string
+		want        string
+		wantErr     bool
+	}{
+		{
+			name:        "default repository",
+			kubeVersion: "v1.25.3",
+			want:        "kindest/node:v1.25.3@" + digest1253,
+		},
+		{
+			name:        "version without v",
+			image:       "kindest/node:v1.24.7",
+			kubeVersion: "1.25.3",
+			want:        "kindest/node:v1.25.3@" + digest1253,
+		},
+		{
+			name:        "version of the kind library release",
+			kubeVersion: "v1.29.4",
+			want:        "kindest/node:v1.29.4@sha256:3abb816a5b1061fb15c6e9e60856ec40d56b7b52bcea5f5f1350bc6e2320b6f8",
+		},
+		{
+			name:        "version of an older kind release",
+			kubeVersion: "v1.25.2",
+			want:        "kindest/node:v1.25.2@sha256:9be91e9e9cdf116809841fc77ebdb8845443c4c72fe5218f3ae9eb57fdb4bace",
+		},
+		{
+			name:        "registry with port",
+			image:       "host:5000/kindest/node:v1.24.7",
+			kubeVersion: "v1.25.3",
+			want:        "host:5000/kindest/node:v1.25.3@" + digest1253,
+		},
+		{
+			name:        "image pinned by digest",
+			image:       "host:5000/kindest/node:v1.24.7@sha256:577c630ce8e509131eab1aea12c022190978dd2f745aac5eb1fe65c0807eb315",
+			kubeVersion: "v1.25.3",
+			want:        "host:5000/kindest/node:v1.25.3@" + digest1253,
+		},
+		{
+			name:        "repository override",
+			kubeVersion: "v1.25.3",
+			overrides:   map[string]string{RepositoryKey: "mirror:5000/kindest/node"},
+			want:        "mirror:5000/kindest/node:v1.25.3@" + digest1253,
+		},
+		{
+			name:        "repository override ignored for a custom repository",
+			image:       "host:5000/kindest/node",
+			kubeVersion: "v1.25.3",
+			overrides:   map[string]string{RepositoryKey: "mirror:5000/kindest/node"},
+			want:        "host:5000/kindest/node:v1.25.3@" + digest1253,
+		},
+		{
+			name:        "version override",
+			kubeVersion: "v1.26.0",
+			overrides:   map[string]string{"v1.26.0": "mirror:5000/node:v1.26.0"},
+			want:        "mirror:5000/node:v1.26.0",
+		},
+		{
+			name:        "unknown version",
+			kubeVersion: "v1.26.0",
+			wantErr:     true,
+		},
+		{
+			name:        "invalid version",
+			kubeVersion: "latest",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			image, err := Resolve(tt.image, tt.kubeVersion, tt.overrides)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(image).To(Equal(tt.want))
+		})
+	}
+}
+
+// the default node image of the kind library the provider is built with must be known
+func TestResolveLibraryDefault(t *testing.T) {
+	g := NewWithT(t)
+	resolved, err := Resolve("", TagKubeVersion(defaults.Image), nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resolved).To(Equal(defaults.Image))
+}
+
+func TestTagKubeVersion(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(TagKubeVersion("host:5000/kindest/node:v1.25.3@" + digest1253)).To(Equal("v1.25.3"))
+	g.Expect(TagKubeVersion("host:5000/kindest/node@" + digest1253)).To(BeEmpty())
+	g.Expect(TagKubeVersion("kindest/node:latest")).To(BeEmpty())
+}
+
+func TestSameImage(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(SameImage("kindest/node:v1.25.3", "kindest/node:v1.25.3@"+digest1253)).To(BeTrue())
+	g.Expect(SameImage("kindest/node:v1.25.3", "host:5000/kindest/node:v1.25.3")).To(BeFalse())
+	g.Expect(SameImage("kindest/node:v1.25.3@"+digest1253, "kindest/node:v1.25.3@sha256:other")).To(BeFalse())
+}